@@ -13,8 +13,14 @@ func main() {
 	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
 
 	appName := flag.String("app-name", "rr", "Load balance app to run")
+	configPath := flag.String("config", "", "YAML or JSON file describing the load balancer, used instead of -app-name")
 	flag.Parse()
 
+	if *configPath != "" {
+		app.RunConfigFileApp(logger, *configPath)
+		return
+	}
+
 	switch *appName {
 	case "rr":
 		app.RunRoundRobinApp(logger)
@@ -28,6 +34,46 @@ func main() {
 		app.RunLowestLatencyApp(logger)
 	case "rb":
 		app.RunResourceBaseApp(logger)
+	case "p2c":
+		app.RunPowerOfTwoChoicesApp(logger)
+	case "ch":
+		app.RunConsistentHashApp(logger)
+	case "mh":
+		app.RunMaglevApp(logger)
+	case "rh":
+		app.RunRendezvousHashApp(logger)
+	case "bh":
+		app.RunBoundedLoadHashApp(logger)
+	case "swr":
+		app.RunSmoothWeightRoundRobinApp(logger)
+	case "wlc":
+		app.RunWeightedLeastConnectionApp(logger)
+	case "pe":
+		app.RunPeakEWMAApp(logger)
+	case "wrb":
+		app.RunWeightedResourceBaseApp(logger)
+	case "rd":
+		app.RunRandomApp(logger)
+	case "wrd":
+		app.RunWeightedRandomApp(logger)
+	case "sticky":
+		app.RunStickySessionApp(logger)
+	case "hc":
+		app.RunHealthCheckApp(logger)
+	case "od":
+		app.RunOutlierDetectionApp(logger)
+	case "cb":
+		app.RunCircuitBreakerApp(logger)
+	case "retry":
+		app.RunRetryApp(logger)
+	case "hedge":
+		app.RunHedgingApp(logger)
+	case "pool":
+		app.RunDynamicPoolApp(logger)
+	case "admin":
+		app.RunAdminApp(logger)
+	case "swap":
+		app.RunHotSwapApp(logger)
 	default:
 		logger.Fatal().Msg("[ERROR] app not available")
 	}
@@ -6,6 +6,7 @@ require (
 	github.com/go-faker/faker/v4 v4.6.1
 	github.com/gorilla/mux v1.8.1
 	github.com/rs/zerolog v1.34.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,171 @@
+package algorithms
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// DefaultLoadFactor caps every backend at 1.25 times its fair share of the
+// requests in flight.
+const DefaultLoadFactor = 1.25
+
+// boundedLoadHash is consistent hashing with bounded loads. A client sticks to
+// the backend owning its ring position until that backend goes over the load
+// cap, then the request walks clockwise to the next backend under the cap.
+type boundedLoadHash struct {
+	pool         *pool.Pool
+	key          KeyExtractor
+	virtualNodes int
+	ring         poolCache[boundedRing]
+	loadFactor   float64
+	inFlight     inFlightTracker
+	proxyCache   sync.Map
+}
+
+// boundedRing is the ring of the pool members along with their total weight
+// the load caps are computed from.
+type boundedRing struct {
+	*hashRing
+	totalWeight int
+}
+
+func NewBoundedLoadHashAlg(
+	backends *pool.Pool, virtualNodes int, loadFactor float64, key KeyExtractor,
+) (*boundedLoadHash, error) {
+	if backends == nil || backends.Len() == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	if virtualNodes <= 0 {
+		return nil, errs.ErrInvalidVirtualNodes
+	}
+
+	if loadFactor <= 1 {
+		return nil, errs.ErrInvalidLoadFactor
+	}
+
+	blh := &boundedLoadHash{
+		pool:         backends,
+		key:          key,
+		virtualNodes: virtualNodes,
+		loadFactor:   loadFactor,
+		proxyCache:   sync.Map{},
+	}
+
+	return blh, nil
+}
+
+func (lb *boundedLoadHash) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *boundedLoadHash) NextBackend(r *http.Request, filter Filter) (*url.URL, func()) {
+	key := affinityKey(r, lb.key)
+
+	// The cap is checked and the request counted under one lock, so a burst
+	// of selections cannot push a backend over its cap
+	nextUrl, release := lb.inFlight.reserve(func() *url.URL { return lb.getNextBackend(key, filter) })
+	if nextUrl == nil {
+		return nil, release
+	}
+
+	// Log the next URL to which the request will be forwarded
+	log.Println("---------------------------------------------------------")
+	log.Printf(
+		"[INFO] key: %s -> load balancer forwarding request to: %v\n",
+		key, nextUrl.String(),
+	)
+
+	return nextUrl, release
+}
+
+func (lb *boundedLoadHash) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	// Create a reverse proxy for the next backend
+	proxy := lb.getOrCreateProxy(target)
+
+	// Serve the request using the reverse proxy
+	proxy.ServeHTTP(w, r)
+}
+
+// getNextBackend walks the ring from key to the first backend under its cap.
+// Callers hold the selection lock of the in-flight tracker.
+func (lb *boundedLoadHash) getNextBackend(key string, filter Filter) *url.URL {
+	ring := lb.currentRing()
+	total := lb.inFlight.totalCount() + 1
+	pos := ring.search(hashKey(key))
+
+	for range len(ring.points) {
+		b := ring.backends[ring.points[pos].backendIdx]
+		if filter.Allows(b.GetUrl()) && lb.inFlight.count(b.GetUrl()) < lb.capacity(ring, b, total) {
+			return b.GetUrl()
+		}
+
+		pos = (pos + 1) % len(ring.points)
+	}
+
+	// Every usable backend is full, which only happens when few of them are
+	// left, keep the first usable owner anyway
+	idx := ring.lookup(key, func(idx int) bool {
+		return filter.Allows(ring.backends[idx].GetUrl())
+	})
+	if idx == -1 {
+		return nil
+	}
+
+	return ring.backends[idx].GetUrl()
+}
+
+// currentRing returns the ring of the current pool members.
+func (lb *boundedLoadHash) currentRing() boundedRing {
+	return lb.ring.get(lb.pool, func(backends []*pool.Backend) boundedRing {
+		totalWeight := 0
+		for _, b := range backends {
+			totalWeight += max(b.GetWeight(), 1)
+		}
+
+		return boundedRing{hashRing: newHashRing(backends, lb.virtualNodes), totalWeight: totalWeight}
+	})
+}
+
+// capacity is the weighted share of total requests b may hold, scaled by the
+// load factor and rounded up.
+func (lb *boundedLoadHash) capacity(ring boundedRing, b *pool.Backend, total int64) int64 {
+	share := float64(total) * float64(max(b.GetWeight(), 1)) / float64(ring.totalWeight)
+	return int64(math.Ceil(share * lb.loadFactor))
+}
+
+func (lb *boundedLoadHash) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
+	key := target.String()
+	if proxy, ok := lb.proxyCache.Load(key); ok {
+		return proxy.(*httputil.ReverseProxy)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
+	lb.proxyCache.Store(key, proxy)
+
+	return proxy
+}
+
+func (lb *boundedLoadHash) ExportState() *WarmState {
+	return &WarmState{inFlight: &lb.inFlight}
+}
+
+// ImportState keeps counting the requests in flight on the previous algorithm.
+func (lb *boundedLoadHash) ImportState(state *WarmState) {
+	lb.inFlight.adopt(state.inFlight)
+}
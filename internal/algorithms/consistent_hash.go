@@ -0,0 +1,112 @@
+package algorithms
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// consistentHash maps affinity keys onto a hash ring, unlike sourceIPHash only
+// about 1/N of the clients move when a backend joins or leaves the pool.
+type consistentHash struct {
+	pool         *pool.Pool
+	key          KeyExtractor
+	virtualNodes int
+	ring         poolCache[*hashRing]
+	proxyCache   sync.Map
+}
+
+func NewConsistentHashAlg(
+	backends *pool.Pool, virtualNodes int, key KeyExtractor,
+) (*consistentHash, error) {
+	if backends == nil || backends.Len() == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	if virtualNodes <= 0 {
+		return nil, errs.ErrInvalidVirtualNodes
+	}
+
+	ch := &consistentHash{
+		pool:         backends,
+		key:          key,
+		virtualNodes: virtualNodes,
+		proxyCache:   sync.Map{},
+	}
+
+	return ch, nil
+}
+
+func (lb *consistentHash) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *consistentHash) NextBackend(r *http.Request, filter Filter) (*url.URL, func()) {
+	key := affinityKey(r, lb.key)
+
+	nextUrl := lb.getNextBackend(key, filter)
+	if nextUrl == nil {
+		return nil, noRelease
+	}
+
+	// Log the next URL to which the request will be forwarded
+	log.Println("---------------------------------------------------------")
+	log.Printf(
+		"[INFO] key: %s -> load balancer forwarding request to: %v\n",
+		key, nextUrl.String(),
+	)
+
+	return nextUrl, noRelease
+}
+
+func (lb *consistentHash) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	// Create a reverse proxy for the next backend
+	proxy := lb.getOrCreateProxy(target)
+
+	// Serve the request using the reverse proxy
+	proxy.ServeHTTP(w, r)
+}
+
+func (lb *consistentHash) getNextBackend(key string, filter Filter) *url.URL {
+	ring := lb.currentRing()
+	idx := ring.lookup(key, func(idx int) bool {
+		return filter.Allows(ring.backends[idx].GetUrl())
+	})
+	if idx == -1 {
+		return nil
+	}
+
+	return ring.backends[idx].GetUrl()
+}
+
+// currentRing returns the ring of the current pool members.
+func (lb *consistentHash) currentRing() *hashRing {
+	return lb.ring.get(lb.pool, func(backends []*pool.Backend) *hashRing {
+		return newHashRing(backends, lb.virtualNodes)
+	})
+}
+
+func (lb *consistentHash) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
+	key := target.String()
+	if proxy, ok := lb.proxyCache.Load(key); ok {
+		return proxy.(*httputil.ReverseProxy)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
+	lb.proxyCache.Store(key, proxy)
+
+	return proxy
+}
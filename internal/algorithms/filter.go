@@ -0,0 +1,29 @@
+package algorithms
+
+import (
+	"net/url"
+
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// Filter reports whether a backend may take new requests, e.g. because it
+// passes its health checks. Algorithms skip the backends it rejects and a nil
+// Filter accepts every backend.
+type Filter func(target *url.URL) bool
+
+// Allows reports whether target is accepted, a nil Filter accepts everything.
+func (f Filter) Allows(target *url.URL) bool {
+	return f == nil || f(target)
+}
+
+// usableIndexes returns the positions of the backends accepted by filter.
+func usableIndexes(backends []*pool.Backend, filter Filter) []int {
+	usable := make([]int, 0, len(backends))
+	for idx, b := range backends {
+		if filter.Allows(b.GetUrl()) {
+			usable = append(usable, idx)
+		}
+	}
+
+	return usable
+}
@@ -0,0 +1,103 @@
+package algorithms
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/DucTran999/load-balancing-algo/internal/clientip"
+)
+
+// KeyExtractor derives the affinity key hash based algorithms route on. It
+// returns false when the request does not carry such a key.
+type KeyExtractor func(r *http.Request) (string, bool)
+
+// SourceIPKey keys on the client IP address found by resolver, which only
+// believes forwarding headers set by trusted proxies.
+func SourceIPKey(resolver *clientip.Resolver) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		ip := resolver.ClientIP(r)
+		return ip, ip != ""
+	}
+}
+
+// HeaderKey keys on the value of the named request header, e.g. X-User-ID.
+func HeaderKey(name string) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		value := r.Header.Get(name)
+		return value, value != ""
+	}
+}
+
+// CookieKey keys on the value of the named cookie.
+func CookieKey(name string) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return "", false
+		}
+		return cookie.Value, true
+	}
+}
+
+// PathKey keys on the request path, so every client of a resource hits the
+// same backend cache.
+func PathKey() KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		return r.URL.Path, r.URL.Path != ""
+	}
+}
+
+// QueryKey keys on the value of the named query parameter.
+func QueryKey(name string) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		value := r.URL.Query().Get(name)
+		return value, value != ""
+	}
+}
+
+// CombinedKey joins the keys of all extractors, it misses as soon as one of
+// them does.
+func CombinedKey(extractors ...KeyExtractor) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		parts := make([]string, 0, len(extractors))
+		for _, extract := range extractors {
+			part, ok := extract(r)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, "|"), len(parts) > 0
+	}
+}
+
+// FallbackKey tries the extractors in order and returns the first key found,
+// e.g. FallbackKey(HeaderKey("X-User-ID"), CookieKey("session"), SourceIPKey(resolver)).
+func FallbackKey(extractors ...KeyExtractor) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		for _, extract := range extractors {
+			if key, ok := extract(r); ok {
+				return key, true
+			}
+		}
+		return "", false
+	}
+}
+
+// affinityKey runs extract on r and falls back to the peer address, so a
+// request without the configured key still gets a stable backend. Behind a
+// proxy every peer address is the proxy's, so extract should end with a
+// SourceIPKey in a FallbackKey rather than rely on this.
+func affinityKey(r *http.Request, extract KeyExtractor) string {
+	if extract != nil {
+		if key, ok := extract(r); ok {
+			return key
+		}
+	}
+
+	if remote, ok := clientip.RemoteAddr(r); ok {
+		return remote.String()
+	}
+
+	return r.RemoteAddr
+}
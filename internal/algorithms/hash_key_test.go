@@ -0,0 +1,29 @@
+package algorithms
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DucTran999/load-balancing-algo/internal/clientip"
+)
+
+func TestAffinityKeyFallsBackToClientIPBehindProxy(t *testing.T) {
+	resolver, err := clientip.NewResolver(clientip.HeaderXForwardedFor, []string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := FallbackKey(HeaderKey("X-User-ID"), SourceIPKey(resolver))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:41000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	if got := affinityKey(r, key); got != "203.0.113.7" {
+		t.Fatalf("key %q without the header, expected the client IP", got)
+	}
+
+	r.Header.Set("X-User-ID", "alice")
+	if got := affinityKey(r, key); got != "alice" {
+		t.Fatalf("key %q, expected the header value", got)
+	}
+}
@@ -0,0 +1,94 @@
+package algorithms
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// DefaultVirtualNodes is the number of ring points a backend of weight 1 owns.
+const DefaultVirtualNodes = 160
+
+type ringPoint struct {
+	hash       uint64
+	backendIdx int
+}
+
+// hashRing places every backend on a ring of 64-bit hashes. A backend owns
+// virtualNodes * weight points, derived from its URL, so adding or removing one
+// backend only moves the keys that land on its own arcs. The points index the
+// backends the ring was built from.
+type hashRing struct {
+	backends []*pool.Backend
+	points   []ringPoint
+}
+
+func newHashRing(backends []*pool.Backend, virtualNodes int) *hashRing {
+	ring := &hashRing{backends: backends}
+
+	for idx, b := range backends {
+		nodeKey := b.GetUrl().String()
+		replicas := virtualNodes * max(b.GetWeight(), 1)
+
+		for i := range replicas {
+			ring.points = append(ring.points, ringPoint{
+				hash:       hashKey(nodeKey + "#" + strconv.Itoa(i)),
+				backendIdx: idx,
+			})
+		}
+	}
+
+	sort.Slice(ring.points, func(i, j int) bool {
+		return ring.points[i].hash < ring.points[j].hash
+	})
+
+	return ring
+}
+
+// search returns the position of the first point clockwise from hash.
+func (r *hashRing) search(hash uint64) int {
+	pos := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+
+	// Wrap around to the start of the ring
+	if pos == len(r.points) {
+		pos = 0
+	}
+
+	return pos
+}
+
+// lookup returns the index of the first usable backend clockwise from key, or
+// -1 when no backend is usable. Keys of an unusable backend move on to the
+// next backends of the ring while every other key stays in place.
+func (r *hashRing) lookup(key string, usable func(backendIdx int) bool) int {
+	pos := r.search(hashKey(key))
+
+	for range len(r.points) {
+		if usable(r.points[pos].backendIdx) {
+			return r.points[pos].backendIdx
+		}
+		pos = (pos + 1) % len(r.points)
+	}
+
+	return -1
+}
+
+// hashKey hashes s with FNV-1a and runs the result through a 64-bit finalizer,
+// FNV alone clusters badly for keys that only differ in their last characters.
+func hashKey(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s)) //nolint:gosec
+
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return x
+}
@@ -0,0 +1,126 @@
+package algorithms
+
+import (
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+const (
+	// movedKeys is how many keys the remapping tests hash
+	movedKeys = 100_000
+	// movedTolerance is how far the moved share may stray from 1/N
+	movedTolerance = 0.25
+)
+
+// newTestPool returns a pool of backends http://backend-<i>, one per weight.
+func newTestPool(t *testing.T, weights ...int) *pool.Pool {
+	t.Helper()
+
+	backends := pool.New()
+	for idx, weight := range weights {
+		target, err := url.Parse(fmt.Sprintf("http://backend-%d", idx))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := backends.Add(target, weight); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return backends
+}
+
+// assertOnlyRemovedKeysMoved checks that the keys whose owner changed all
+// belonged to removed, and that they are about 1/n of all keys.
+func assertOnlyRemovedKeysMoved(t *testing.T, before, after map[string]string, removed string, n int) {
+	t.Helper()
+
+	moved := 0
+	for key, owner := range before {
+		if after[key] == owner {
+			continue
+		}
+
+		moved++
+		if owner != removed {
+			t.Fatalf("key %s moved from %s to %s, only keys of %s should move", key, owner, after[key], removed)
+		}
+	}
+
+	for key, owner := range after {
+		if owner == removed {
+			t.Fatalf("key %s still maps to the removed backend", key)
+		}
+	}
+
+	share, expected := float64(moved)/float64(len(before)), 1/float64(n)
+	if math.Abs(share-expected) > expected*movedTolerance {
+		t.Fatalf("moved %.4f of the keys, expected about %.4f", share, expected)
+	}
+}
+
+func TestHashRingRemovalMovesOnlyKeysOfRemovedBackend(t *testing.T) {
+	const n = 5
+	backends := newTestPool(t, 1, 1, 1, 1, 1)
+
+	owners := func() map[string]string {
+		ring := newHashRing(backends.Backends(), DefaultVirtualNodes)
+		usable := func(int) bool { return true }
+
+		owners := make(map[string]string, movedKeys)
+		for i := range movedKeys {
+			key := fmt.Sprintf("key-%d", i)
+			owners[key] = ring.backends[ring.lookup(key, usable)].GetUrl().String()
+		}
+		return owners
+	}
+
+	before := owners()
+
+	removed := backends.Backends()[2].GetUrl()
+	if err := backends.Remove(removed); err != nil {
+		t.Fatal(err)
+	}
+
+	assertOnlyRemovedKeysMoved(t, before, owners(), removed.String(), n)
+}
+
+func TestConsistentHashRemovalMovesOnlyKeysOfRemovedBackend(t *testing.T) {
+	const n = 8
+	backends := newTestPool(t, 1, 1, 1, 1, 1, 1, 1, 1)
+
+	alg, err := NewConsistentHashAlg(backends, DefaultVirtualNodes, HeaderKey("X-Key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owners := func() map[string]string {
+		owners := make(map[string]string, movedKeys)
+		for i := range movedKeys {
+			key := fmt.Sprintf("user-%d", i)
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("X-Key", key)
+
+			target, release := alg.NextBackend(r, nil)
+			release()
+			owners[key] = target.String()
+		}
+		return owners
+	}
+
+	before := owners()
+
+	removed := backends.Backends()[5].GetUrl()
+	if err := backends.Remove(removed); err != nil {
+		t.Fatal(err)
+	}
+
+	assertOnlyRemovedKeysMoved(t, before, owners(), removed.String(), n)
+}
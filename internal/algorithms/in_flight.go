@@ -0,0 +1,83 @@
+package algorithms
+
+import (
+	"net/url"
+	"sync"
+	"sync/atomic"
+)
+
+// inFlightTracker counts the requests the load balancer has forwarded to each
+// backend and not seen complete yet. It works with any upstream because it
+// never asks the backend for its own numbers.
+type inFlightTracker struct {
+	counters sync.Map // backend url -> *atomic.Int64
+	// mutex serializes the selections, a release needs no lock since a count
+	// going down meanwhile never makes a selection overshoot
+	mutex sync.Mutex
+}
+
+// noRelease is the release of a selection that reserved nothing.
+func noRelease() {}
+
+// reserve runs pick and counts the request on the backend it chose before the
+// next selection reads the counts, so concurrent requests do not herd onto the
+// same minimum. The returned release must be called once the request is done.
+func (t *inFlightTracker) reserve(pick func() *url.URL) (*url.URL, func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	target := pick()
+	if target == nil {
+		return nil, noRelease
+	}
+
+	return target, t.acquire(target)
+}
+
+// acquire marks one more request in flight to target, the returned func must
+// be called once the proxied request is done.
+func (t *inFlightTracker) acquire(target *url.URL) func() {
+	counter := t.counter(target)
+	counter.Add(1)
+
+	return func() {
+		counter.Add(-1)
+	}
+}
+
+func (t *inFlightTracker) count(target *url.URL) int64 {
+	return t.counter(target).Load()
+}
+
+func (t *inFlightTracker) totalCount() int64 {
+	var total int64
+	t.counters.Range(func(_, counter any) bool {
+		total += counter.(*atomic.Int64).Load()
+		return true
+	})
+
+	return total
+}
+
+// adopt shares the counters of previous, so the requests still in flight on
+// the algorithm previous belongs to are counted until they complete.
+func (t *inFlightTracker) adopt(previous *inFlightTracker) {
+	if previous == nil || previous == t {
+		return
+	}
+
+	previous.counters.Range(func(key, counter any) bool {
+		t.counters.Store(key, counter)
+		return true
+	})
+}
+
+func (t *inFlightTracker) counter(target *url.URL) *atomic.Int64 {
+	key := target.String()
+	if counter, ok := t.counters.Load(key); ok {
+		return counter.(*atomic.Int64)
+	}
+
+	counter, _ := t.counters.LoadOrStore(key, &atomic.Int64{})
+	return counter.(*atomic.Int64)
+}
@@ -0,0 +1,198 @@
+package algorithms
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/pkg/loadreport"
+)
+
+const (
+	// DefaultLatencyDecay is the time constant of the latency moving average,
+	// a sample older than this weighs about 37% of a fresh one.
+	DefaultLatencyDecay = 10 * time.Second
+
+	// latencyPenalty is the latency charged for a failed request and for a
+	// backend that has requests in flight but no measurement yet.
+	latencyPenalty = 5 * time.Second
+)
+
+type latencyAverage struct {
+	value   float64 // nanoseconds
+	updated time.Time
+}
+
+// latencyTracker keeps an exponentially weighted moving average of the round
+// trip time the proxy measured for each backend. The weight of a sample decays
+// with the time elapsed since the previous one, so idle backends are not stuck
+// with an old value. In peak mode a sample above the average replaces it right
+// away and only the way down is smoothed, which reacts fast to a slowing
+// backend.
+//
+// The latency backends report about themselves is averaged apart, always
+// smoothed, and only stands in for a backend the proxy has not measured yet: a
+// backend cannot talk its way out of the latency the proxy sees, nor can one
+// high report replace a peak average.
+type latencyTracker struct {
+	decay    time.Duration
+	peak     bool
+	averages map[string]*latencyAverage
+	reported map[string]*latencyAverage
+	mutex    sync.Mutex
+}
+
+func newLatencyTracker(decay time.Duration, peak bool) *latencyTracker {
+	return &latencyTracker{
+		decay:    decay,
+		peak:     peak,
+		averages: make(map[string]*latencyAverage),
+		reported: make(map[string]*latencyAverage),
+	}
+}
+
+func (t *latencyTracker) observe(target *url.URL, rtt time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.update(t.averages, target.String(), float64(rtt), t.peak)
+}
+
+// update feeds sample into the average of key in averages. Callers hold the
+// mutex.
+func (t *latencyTracker) update(averages map[string]*latencyAverage, key string, sample float64, peak bool) {
+	now := time.Now()
+
+	avg, ok := averages[key]
+	if !ok {
+		averages[key] = &latencyAverage{value: sample, updated: now}
+		return
+	}
+
+	if peak && sample > avg.value {
+		avg.value = sample
+	} else {
+		w := math.Exp(-float64(now.Sub(avg.updated)) / float64(t.decay))
+		avg.value = avg.value*w + sample*(1-w)
+	}
+	avg.updated = now
+}
+
+// seed starts the averages not measured yet from those of previous.
+func (t *latencyTracker) seed(previous *latencyTracker) {
+	if previous == nil || previous == t {
+		return
+	}
+
+	// Copied first so the two mutexes are never held together
+	previous.mutex.Lock()
+	averages := copyAverages(previous.averages)
+	reported := copyAverages(previous.reported)
+	previous.mutex.Unlock()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	mergeAverages(t.averages, averages)
+	mergeAverages(t.reported, reported)
+}
+
+func copyAverages(averages map[string]*latencyAverage) map[string]latencyAverage {
+	copied := make(map[string]latencyAverage, len(averages))
+	for key, avg := range averages {
+		copied[key] = *avg
+	}
+
+	return copied
+}
+
+// mergeAverages adds the averages of from missing in into.
+func mergeAverages(into map[string]*latencyAverage, from map[string]latencyAverage) {
+	for key, avg := range from {
+		if _, ok := into[key]; !ok {
+			into[key] = &avg
+		}
+	}
+}
+
+// observeReport feeds the latency target reported about itself into its
+// reported average, reports without a latency metric are ignored.
+func (t *latencyTracker) observeReport(target *url.URL, report loadreport.Report) {
+	latencyMs, ok := report.Named(loadreport.LatencyMetric)
+	if !ok {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.update(t.reported, target.String(), latencyMs*float64(time.Millisecond), false)
+}
+
+// average returns the moving average the proxy measured for target, or the one
+// target reported when it was not measured yet. False when there is neither.
+func (t *latencyTracker) average(target *url.URL) (time.Duration, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	avg, ok := t.averages[target.String()]
+	if !ok {
+		avg, ok = t.reported[target.String()]
+	}
+	if !ok {
+		return 0, false
+	}
+
+	return time.Duration(avg.value), true
+}
+
+// instrument hooks proxy so every response to target feeds the average with
+// the time to response headers, and every failure with latencyPenalty. Requests
+// canceled without a cause are not held against the backend.
+func (t *latencyTracker) instrument(proxy *httputil.ReverseProxy, target *url.URL) {
+	next := proxy.ModifyResponse
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if rtt, ok := proxyElapsed(resp.Request); ok {
+			t.observe(target, rtt)
+		}
+
+		if next != nil {
+			return next(resp)
+		}
+		return nil
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("[ERROR] proxy to %v failed: %v\n", target.String(), err)
+
+		// The backend is not to blame for a request given up on
+		if !canceledWithoutCause(r, err) {
+			t.observe(target, latencyPenalty)
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+type proxyStartKey struct{}
+
+// withProxyStart stamps r with the time it is handed to the reverse proxy, the
+// outgoing request shares the context so ModifyResponse can read it back.
+func withProxyStart(r *http.Request) *http.Request {
+	ctx := context.WithValue(r.Context(), proxyStartKey{}, time.Now())
+	return r.WithContext(ctx)
+}
+
+// proxyElapsed returns the time spent since withProxyStart stamped r.
+func proxyElapsed(r *http.Request) (time.Duration, bool) {
+	start, ok := r.Context().Value(proxyStartKey{}).(time.Time)
+	if !ok {
+		return 0, false
+	}
+
+	return time.Since(start), true
+}
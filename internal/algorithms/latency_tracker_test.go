@@ -0,0 +1,82 @@
+package algorithms
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/pkg/loadreport"
+)
+
+func latencyReport(latency time.Duration) loadreport.Report {
+	return loadreport.Report{
+		NamedMetrics: map[string]float64{
+			loadreport.LatencyMetric: float64(latency) / float64(time.Millisecond),
+		},
+	}
+}
+
+func TestLatencyTrackerReportDoesNotOverrideMeasurement(t *testing.T) {
+	target, _ := url.Parse("http://backend-0")
+	tracker := newLatencyTracker(DefaultLatencyDecay, true)
+
+	tracker.observe(target, 20*time.Millisecond)
+	tracker.observeReport(target, latencyReport(2*time.Second))
+
+	if avg, _ := tracker.average(target); avg != 20*time.Millisecond {
+		t.Fatalf("average %v after a high report, expected the measured 20ms", avg)
+	}
+}
+
+func TestLatencyTrackerReportStandsInUntilMeasured(t *testing.T) {
+	target, _ := url.Parse("http://backend-0")
+	tracker := newLatencyTracker(DefaultLatencyDecay, true)
+
+	if _, ok := tracker.average(target); ok {
+		t.Fatal("average of a backend neither measured nor reported")
+	}
+
+	tracker.observeReport(target, latencyReport(50*time.Millisecond))
+	if avg, ok := tracker.average(target); !ok || avg != 50*time.Millisecond {
+		t.Fatalf("average %v, expected the reported 50ms", avg)
+	}
+
+	tracker.observe(target, 10*time.Millisecond)
+	if avg, _ := tracker.average(target); avg != 10*time.Millisecond {
+		t.Fatalf("average %v, expected the measured 10ms", avg)
+	}
+}
+
+func TestLatencyTrackerIgnoresCanceledRequests(t *testing.T) {
+	target, _ := url.Parse("http://backend-0")
+	tracker := newLatencyTracker(DefaultLatencyDecay, true)
+	tracker.observe(target, 20*time.Millisecond)
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	tracker.instrument(proxy, target)
+	observeOutcome(proxy, target)
+
+	// A client giving up, or a hedge canceled once another attempt won
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	proxy.ErrorHandler(httptest.NewRecorder(), r, context.Canceled)
+
+	if avg, _ := tracker.average(target); avg != 20*time.Millisecond {
+		t.Fatalf("average %v after a canceled request, expected 20ms", avg)
+	}
+
+	// A request canceled for a cause, e.g. a per try timeout, is a failure
+	ctx, cancelCause := context.WithCancelCause(context.Background())
+	cancelCause(errors.New("per try timeout exceeded"))
+	r = httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	proxy.ErrorHandler(httptest.NewRecorder(), r, context.Canceled)
+
+	if avg, _ := tracker.average(target); avg != latencyPenalty {
+		t.Fatalf("average %v after a timed out request, expected %v", avg, latencyPenalty)
+	}
+}
@@ -8,70 +8,82 @@ import (
 	"sync"
 
 	"github.com/DucTran999/load-balancing-algo/internal/errs"
-	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
 )
 
 type leastConnectionAlg struct {
-	backends   []*backend.SimpleHTTPServer
+	pool       *pool.Pool
+	inFlight   inFlightTracker
 	proxyCache sync.Map
 }
 
-func NewLeastConnectionAlg(targets []*backend.SimpleHTTPServer) (*leastConnectionAlg, error) {
-	if len(targets) == 0 {
+func NewLeastConnectionAlg(backends *pool.Pool) (*leastConnectionAlg, error) {
+	if backends == nil || backends.Len() == 0 {
 		return nil, errs.ErrNoTargetServersFound
 	}
 
-	// Validate backend URLs
-	for _, target := range targets {
-		if target.GetUrl() == nil {
-			return nil, errs.ErrInvalidBackendUrl
-		}
-	}
-
 	return &leastConnectionAlg{
-		backends:   targets,
+		pool:       backends,
 		proxyCache: sync.Map{},
 	}, nil
 }
 
 func (lc *leastConnectionAlg) ForwardRequest(w http.ResponseWriter, r *http.Request) {
-	url := lc.getNextBackend()
+	target, release := lc.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
 
+	lc.Forward(w, r, target)
+}
+
+func (lc *leastConnectionAlg) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	// The request is counted on its backend as part of the selection
+	return lc.inFlight.reserve(func() *url.URL { return lc.getNextBackend(filter) })
+}
+
+func (lc *leastConnectionAlg) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
 	log.Println("-----------------------------------------------------------------")
 	// Log the next URL to which the request will be forwarded
-	log.Printf("[INFO] load balancer forwarding request to: %v\n", url.String())
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
 
-	proxy := lc.getOrCreateProxy(&url)
+	proxy := lc.getOrCreateProxy(target)
 
 	proxy.ServeHTTP(w, r)
 }
 
-func (lc *leastConnectionAlg) getNextBackend() url.URL {
-	// Only one backend server return it intermediately
-	if len(lc.backends) == 1 {
-		return *lc.backends[0].GetUrl()
-	}
-
-	// Lookup the backends got least connection
-	minConnection := lc.backends[0].GetConnection()
-	backendIdx := 0
-	backendConnections := []int{minConnection}
+func (lc *leastConnectionAlg) getNextBackend(filter Filter) *url.URL {
+	backends := lc.pool.Backends()
+	// Lookup the usable backend got least requests in flight
+	var minConnection int64
+	backendIdx := -1
+	backendConnections := make([]int64, 0, len(backends))
+
+	for idx := range backends {
+		connection := lc.inFlight.count(backends[idx].GetUrl())
+		backendConnections = append(backendConnections, connection)
+		if !filter.Allows(backends[idx].GetUrl()) {
+			continue
+		}
 
-	for idx := 1; idx < len(lc.backends); idx++ {
-		backend := lc.backends[idx]
-		backendConnections = append(backendConnections, backend.GetConnection())
-		if minConnection > lc.backends[idx].GetConnection() {
-			minConnection = backend.GetConnection()
+		if backendIdx == -1 || minConnection > connection {
+			minConnection = connection
 			backendIdx = idx
 		}
 	}
 
+	if backendIdx == -1 {
+		return nil
+	}
+
 	log.Printf(
 		"[INFO] backend connections: %v, select: %d, connection: %d \n",
 		backendConnections, backendIdx, minConnection,
 	)
 
-	return *lc.backends[backendIdx].GetUrl()
+	return backends[backendIdx].GetUrl()
 }
 
 func (lb *leastConnectionAlg) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
@@ -81,7 +93,17 @@ func (lb *leastConnectionAlg) getOrCreateProxy(target *url.URL) *httputil.Revers
 	}
 
 	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
 	lb.proxyCache.Store(key, proxy)
 
 	return proxy
 }
+
+func (lc *leastConnectionAlg) ExportState() *WarmState {
+	return &WarmState{inFlight: &lc.inFlight}
+}
+
+// ImportState keeps counting the requests in flight on the previous algorithm.
+func (lc *leastConnectionAlg) ImportState(state *WarmState) {
+	lc.inFlight.adopt(state.inFlight)
+}
@@ -0,0 +1,77 @@
+package algorithms
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/pkg/loadreport"
+)
+
+type storedReport struct {
+	report     loadreport.Report
+	receivedAt time.Time
+}
+
+// loadReportStore keeps the latest load report received from each backend, so
+// resource based algorithms can balance servers running in other processes.
+type loadReportStore struct {
+	reports sync.Map // backend url -> storedReport
+}
+
+func (s *loadReportStore) record(target *url.URL, report loadreport.Report) {
+	s.reports.Store(target.String(), storedReport{report: report, receivedAt: time.Now()})
+}
+
+// seed stores the reports of previous that are newer than those of s.
+func (s *loadReportStore) seed(previous *loadReportStore) {
+	if previous == nil || previous == s {
+		return
+	}
+
+	previous.reports.Range(func(key, stored any) bool {
+		current, ok := s.reports.Load(key)
+		if !ok || current.(storedReport).receivedAt.Before(stored.(storedReport).receivedAt) {
+			s.reports.Store(key, stored)
+		}
+		return true
+	})
+}
+
+// latest returns the last report of target and when it arrived, false when the
+// backend has not reported yet.
+func (s *loadReportStore) latest(target *url.URL) (loadreport.Report, time.Time, bool) {
+	stored, ok := s.reports.Load(target.String())
+	if !ok {
+		return loadreport.Report{}, time.Time{}, false
+	}
+
+	sr := stored.(storedReport)
+	return sr.report, sr.receivedAt, true
+}
+
+// instrument hooks proxy so the load report attached to each response from
+// target is recorded. The header is internal to the load balancer and is
+// removed before the response reaches the client.
+func (s *loadReportStore) instrument(proxy *httputil.ReverseProxy, target *url.URL) {
+	next := proxy.ModifyResponse
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if value := resp.Header.Get(loadreport.Header); value != "" {
+			report, err := loadreport.Parse(value)
+			if err != nil {
+				log.Printf("[WARN] ignore load report from %v: %v\n", target.String(), err)
+			} else {
+				s.record(target, report)
+			}
+			resp.Header.Del(loadreport.Header)
+		}
+
+		if next != nil {
+			return next(resp)
+		}
+		return nil
+	}
+}
@@ -9,28 +9,33 @@ import (
 	"time"
 
 	"github.com/DucTran999/load-balancing-algo/internal/errs"
-	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/DucTran999/load-balancing-algo/pkg/loadreport"
 )
 
+// lowestLatencyAlg picks the backend with the lowest smoothed latency, whatever
+// its requests in flight; peakEWMA is the variant weighing them. Backends not
+// measured yet are assumed as fast as the measured average.
 type lowestLatencyAlg struct {
-	backends   []*backend.SimpleHTTPServer
+	pool       *pool.Pool
+	latency    *latencyTracker
 	proxyCache sync.Map
 }
 
-func NewLowestLatencyAlg(targets []*backend.SimpleHTTPServer) (*lowestLatencyAlg, error) {
-	if len(targets) == 0 {
+func NewLowestLatencyAlg(
+	backends *pool.Pool, decay time.Duration,
+) (*lowestLatencyAlg, error) {
+	if backends == nil || backends.Len() == 0 {
 		return nil, errs.ErrNoTargetServersFound
 	}
 
-	// Validate backend URLs
-	for _, target := range targets {
-		if target.GetUrl() == nil {
-			return nil, errs.ErrInvalidBackendUrl
-		}
+	if decay <= 0 {
+		return nil, errs.ErrInvalidLatencyDecay
 	}
 
 	lr := &lowestLatencyAlg{
-		backends:   targets,
+		pool:       backends,
+		latency:    newLatencyTracker(decay, false),
 		proxyCache: sync.Map{},
 	}
 
@@ -38,16 +43,30 @@ func NewLowestLatencyAlg(targets []*backend.SimpleHTTPServer) (*lowestLatencyAlg
 }
 
 func (lb *lowestLatencyAlg) ForwardRequest(w http.ResponseWriter, r *http.Request) {
-	nextUrl := lb.getNextBackend()
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
 
+func (lb *lowestLatencyAlg) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	return lb.getNextBackend(filter), noRelease
+}
+
+func (lb *lowestLatencyAlg) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
 	// Log the next URL to which the request will be forwarded
-	log.Printf("[INFO] load balancer forwarding request to: %v\n", nextUrl.String())
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
 
 	// Create a reverse proxy for the next backend
-	proxy := lb.getOrCreateProxy(nextUrl)
+	proxy := lb.getOrCreateProxy(target)
 
-	// Serve the request using the reverse proxy
-	proxy.ServeHTTP(w, r)
+	// Serve the request using the reverse proxy, the start time lets the
+	// proxy measure the round trip
+	proxy.ServeHTTP(w, withProxyStart(r))
 }
 
 func (lb *lowestLatencyAlg) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
@@ -57,35 +76,90 @@ func (lb *lowestLatencyAlg) getOrCreateProxy(target *url.URL) *httputil.ReverseP
 	}
 
 	proxy := httputil.NewSingleHostReverseProxy(target)
+	lb.latency.instrument(proxy, target)
+	observeOutcome(proxy, target)
 	lb.proxyCache.Store(key, proxy)
 
 	return proxy
 }
 
-func (lb *lowestLatencyAlg) getNextBackend() *url.URL {
-	if len(lb.backends) == 1 {
-		return lb.backends[0].GetUrl()
-	}
+func (lb *lowestLatencyAlg) getNextBackend(filter Filter) *url.URL {
+	backends := lb.pool.Backends()
+	latencies, measured := lb.latencies(backends)
 
-	minLatency := lb.backends[0].Latency()
-	backendIdx := 0
-	backendLatency := []time.Duration{minLatency}
+	var minLatency time.Duration
+	backendIdx := -1
 
-	for idx := 1; idx < len(lb.backends); idx++ {
-		backend := lb.backends[idx]
-		backendLatency = append(backendLatency, backend.Latency())
+	for idx := range backends {
+		if !filter.Allows(backends[idx].GetUrl()) {
+			continue
+		}
 
-		if minLatency > backend.Latency() {
-			minLatency = backend.Latency()
+		// On a tie a backend not measured yet wins, so each one gets probed
+		latency := latencies[idx]
+		if backendIdx == -1 || minLatency > latency ||
+			minLatency == latency && measured[backendIdx] && !measured[idx] {
+			minLatency = latency
 			backendIdx = idx
 		}
 	}
 
+	if backendIdx == -1 {
+		return nil
+	}
+
 	log.Println("--------------------------------------------------------")
 	log.Printf(
 		"[INFO] backend latency: %v, select: %d, latency: %v\n",
-		backendLatency, backendIdx, minLatency,
+		latencies, backendIdx, minLatency,
 	)
 
-	return lb.backends[backendIdx].GetUrl()
+	return backends[backendIdx].GetUrl()
+}
+
+// latencies returns the latency average of every backend and whether it was
+// measured. Unmeasured backends get the average of the measured ones, so they
+// are probed without drawing all the traffic, or latencyPenalty while nothing
+// is measured at all.
+func (lb *lowestLatencyAlg) latencies(backends []*pool.Backend) ([]time.Duration, []bool) {
+	latencies := make([]time.Duration, len(backends))
+	measured := make([]bool, len(backends))
+
+	var sum time.Duration
+	count := 0
+	for idx, b := range backends {
+		latencies[idx], measured[idx] = lb.latency.average(b.GetUrl())
+		if measured[idx] {
+			sum += latencies[idx]
+			count++
+		}
+	}
+
+	fallback := latencyPenalty
+	if count > 0 {
+		fallback = sum / time.Duration(count)
+	}
+
+	for idx := range latencies {
+		if !measured[idx] {
+			latencies[idx] = fallback
+		}
+	}
+
+	return latencies, measured
+}
+
+// ObserveLoadReport keeps the latency target reports about itself, which stands
+// in for its average until the proxy has measured one.
+func (lb *lowestLatencyAlg) ObserveLoadReport(target *url.URL, report loadreport.Report) {
+	lb.latency.observeReport(target, report)
+}
+
+func (lb *lowestLatencyAlg) ExportState() *WarmState {
+	return &WarmState{latency: lb.latency}
+}
+
+// ImportState starts from the latencies the previous algorithm measured.
+func (lb *lowestLatencyAlg) ImportState(state *WarmState) {
+	lb.latency.seed(state.latency)
 }
@@ -0,0 +1,86 @@
+package algorithms
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLowestLatencyUnmeasuredGetsAverage(t *testing.T) {
+	backends := newTestPool(t, 1, 1, 1)
+	alg, err := NewLowestLatencyAlg(backends, DefaultLatencyDecay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	members := backends.Backends()
+	alg.latency.observe(members[0].GetUrl(), 10*time.Millisecond)
+	alg.latency.observe(members[1].GetUrl(), 50*time.Millisecond)
+
+	expected := []time.Duration{10 * time.Millisecond, 50 * time.Millisecond, 30 * time.Millisecond}
+	if latencies, _ := alg.latencies(members); !slices.Equal(latencies, expected) {
+		t.Fatalf("latencies %v, expected %v", latencies, expected)
+	}
+
+	if sequence := pickIndexes(t, alg, backends, 1); sequence[0] != 0 {
+		t.Fatalf("picked backend %d, expected the fastest one", sequence[0])
+	}
+}
+
+func TestLowestLatencyProbesUnmeasuredOnTie(t *testing.T) {
+	backends := newTestPool(t, 1, 1)
+	alg, err := NewLowestLatencyAlg(backends, DefaultLatencyDecay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alg.latency.observe(backends.Backends()[0].GetUrl(), 10*time.Millisecond)
+
+	if sequence := pickIndexes(t, alg, backends, 1); sequence[0] != 1 {
+		t.Fatalf("picked backend %d, expected the unmeasured one", sequence[0])
+	}
+}
+
+// TestLowestLatencyIgnoresInFlightUnlikePeakEWMA sends requests that do not
+// complete: lowest latency keeps the fastest backend, peak EWMA spreads them.
+func TestLowestLatencyIgnoresInFlightUnlikePeakEWMA(t *testing.T) {
+	backends := newTestPool(t, 1, 1)
+	fast, slow := backends.Backends()[0].GetUrl(), backends.Backends()[1].GetUrl()
+
+	lowest, err := NewLowestLatencyAlg(backends, DefaultLatencyDecay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peak, err := NewPeakEWMAAlg(backends, DefaultLatencyDecay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tracker := range []*latencyTracker{lowest.latency, peak.latency} {
+		tracker.observe(fast, 10*time.Millisecond)
+		tracker.observe(slow, 15*time.Millisecond)
+	}
+
+	// pickIndexes releases every pick, so the requests are held here
+	hold := func(alg selector, n int) []int {
+		sequence := make([]int, 0, n)
+		for range n {
+			target, _ := alg.NextBackend(nil, nil)
+			idx := 0
+			if target.String() == slow.String() {
+				idx = 1
+			}
+			sequence = append(sequence, idx)
+		}
+		return sequence
+	}
+
+	if sequence := hold(lowest, 3); !slices.Equal(sequence, []int{0, 0, 0}) {
+		t.Errorf("lowest latency picked %v, expected the fastest backend only", sequence)
+	}
+
+	// 10ms x 1 < 15ms, then 10ms x 2 > 15ms x 1, then 10ms x 2 < 15ms x 2
+	if sequence := hold(peak, 3); !slices.Equal(sequence, []int{0, 1, 0}) {
+		t.Errorf("peak EWMA picked %v, expected [0 1 0]", sequence)
+	}
+}
@@ -0,0 +1,174 @@
+package algorithms
+
+import (
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// DefaultMaglevTableSize is the lookup table size suggested by the Maglev paper,
+// it must be a prime much larger than the number of backends.
+const DefaultMaglevTableSize = 65537
+
+// maglev implements Google's Maglev hashing. Every backend walks its own
+// permutation of the lookup table and claims free slots in turn, which gives
+// an almost even split and little disruption when the pool changes.
+type maglev struct {
+	pool       *pool.Pool
+	key        KeyExtractor
+	tableSize  int
+	table      poolCache[*maglevTable]
+	proxyCache sync.Map
+}
+
+// maglevTable maps every slot to the index of its owner among the backends the
+// table was populated from.
+type maglevTable struct {
+	backends []*pool.Backend
+	slots    []int
+}
+
+func NewMaglevAlg(
+	backends *pool.Pool, tableSize int, key KeyExtractor,
+) (*maglev, error) {
+	if backends == nil || backends.Len() == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	if tableSize <= backends.Len() || !big.NewInt(int64(tableSize)).ProbablyPrime(0) {
+		return nil, errs.ErrInvalidTableSize
+	}
+
+	mg := &maglev{
+		pool:       backends,
+		key:        key,
+		tableSize:  tableSize,
+		proxyCache: sync.Map{},
+	}
+
+	return mg, nil
+}
+
+func (lb *maglev) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *maglev) NextBackend(r *http.Request, filter Filter) (*url.URL, func()) {
+	key := affinityKey(r, lb.key)
+
+	nextUrl := lb.getNextBackend(key, filter)
+	if nextUrl == nil {
+		return nil, noRelease
+	}
+
+	// Log the next URL to which the request will be forwarded
+	log.Println("---------------------------------------------------------")
+	log.Printf(
+		"[INFO] key: %s -> load balancer forwarding request to: %v\n",
+		key, nextUrl.String(),
+	)
+
+	return nextUrl, noRelease
+}
+
+func (lb *maglev) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	// Create a reverse proxy for the next backend
+	proxy := lb.getOrCreateProxy(target)
+
+	// Serve the request using the reverse proxy
+	proxy.ServeHTTP(w, r)
+}
+
+func (lb *maglev) getNextBackend(key string, filter Filter) *url.URL {
+	table := lb.currentTable()
+	slot := hashKey(key) % uint64(len(table.slots))
+
+	// Neighbouring slots belong to well mixed backends, so the keys of an
+	// unusable backend spread over the others
+	for range len(table.slots) {
+		next := table.backends[table.slots[slot]]
+		if filter.Allows(next.GetUrl()) {
+			return next.GetUrl()
+		}
+		slot = (slot + 1) % uint64(len(table.slots))
+	}
+
+	return nil
+}
+
+// currentTable returns the lookup table of the current pool members, a change
+// of the pool only moves the slots the permutations hand over.
+func (lb *maglev) currentTable() *maglevTable {
+	return lb.table.get(lb.pool, func(backends []*pool.Backend) *maglevTable {
+		return newMaglevTable(backends, lb.tableSize)
+	})
+}
+
+// newMaglevTable fills the lookup table following the Maglev paper. Each round
+// a backend claims as many slots as its weight, so heavier backends own a
+// proportionally larger share of the table.
+func newMaglevTable(backends []*pool.Backend, tableSize int) *maglevTable {
+	size := uint64(tableSize)
+	offsets := make([]uint64, len(backends))
+	skips := make([]uint64, len(backends))
+	next := make([]uint64, len(backends))
+
+	for idx, b := range backends {
+		name := b.GetUrl().String()
+		offsets[idx] = hashKey(name+"#offset") % size
+		skips[idx] = hashKey(name+"#skip")%(size-1) + 1
+	}
+
+	table := &maglevTable{backends: backends, slots: make([]int, tableSize)}
+	for slot := range table.slots {
+		table.slots[slot] = -1
+	}
+
+	filled := 0
+	for {
+		for idx, b := range backends {
+			for range max(b.GetWeight(), 1) {
+				// Walk the backend permutation until a free slot shows up
+				slot := (offsets[idx] + next[idx]*skips[idx]) % size
+				for table.slots[slot] >= 0 {
+					next[idx]++
+					slot = (offsets[idx] + next[idx]*skips[idx]) % size
+				}
+
+				table.slots[slot] = idx
+				next[idx]++
+				filled++
+
+				if filled == tableSize {
+					return table
+				}
+			}
+		}
+	}
+}
+
+func (lb *maglev) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
+	key := target.String()
+	if proxy, ok := lb.proxyCache.Load(key); ok {
+		return proxy.(*httputil.ReverseProxy)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
+	lb.proxyCache.Store(key, proxy)
+
+	return proxy
+}
@@ -0,0 +1,14 @@
+package algorithms
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+// TestMain silences the per request logs of the algorithms.
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
@@ -0,0 +1,148 @@
+package algorithms
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/DucTran999/load-balancing-algo/pkg/loadreport"
+)
+
+// peakEWMA scores every backend with its peak sensitive latency average
+// multiplied by the requests in flight plus one, and picks the lowest score.
+// A backend that slows down or piles up work loses traffic right away.
+type peakEWMA struct {
+	pool       *pool.Pool
+	latency    *latencyTracker
+	inFlight   inFlightTracker
+	proxyCache sync.Map
+}
+
+func NewPeakEWMAAlg(backends *pool.Pool, decay time.Duration) (*peakEWMA, error) {
+	if backends == nil || backends.Len() == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	if decay <= 0 {
+		return nil, errs.ErrInvalidLatencyDecay
+	}
+
+	pe := &peakEWMA{
+		pool:       backends,
+		latency:    newLatencyTracker(decay, true),
+		proxyCache: sync.Map{},
+	}
+
+	return pe, nil
+}
+
+func (lb *peakEWMA) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *peakEWMA) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	// The request is counted on its backend as part of the selection
+	return lb.inFlight.reserve(func() *url.URL { return lb.getNextBackend(filter) })
+}
+
+func (lb *peakEWMA) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	// Log the next URL to which the request will be forwarded
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
+
+	// Create a reverse proxy for the next backend
+	proxy := lb.getOrCreateProxy(target)
+
+	// Serve the request using the reverse proxy, the start time lets the
+	// proxy measure the round trip
+	proxy.ServeHTTP(w, withProxyStart(r))
+}
+
+func (lb *peakEWMA) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
+	key := target.String()
+	if proxy, ok := lb.proxyCache.Load(key); ok {
+		return proxy.(*httputil.ReverseProxy)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	lb.latency.instrument(proxy, target)
+	observeOutcome(proxy, target)
+	lb.proxyCache.Store(key, proxy)
+
+	return proxy
+}
+
+func (lb *peakEWMA) getNextBackend(filter Filter) *url.URL {
+	backends := lb.pool.Backends()
+	var minScore time.Duration
+	backendIdx := -1
+	backendScores := make([]time.Duration, 0, len(backends))
+
+	for idx := range backends {
+		score := lb.score(backends[idx].GetUrl())
+		backendScores = append(backendScores, score)
+		if !filter.Allows(backends[idx].GetUrl()) {
+			continue
+		}
+
+		if backendIdx == -1 || minScore > score {
+			minScore = score
+			backendIdx = idx
+		}
+	}
+
+	if backendIdx == -1 {
+		return nil
+	}
+
+	log.Println("--------------------------------------------------------")
+	log.Printf(
+		"[INFO] backend scores: %v, select: %d, score: %v\n",
+		backendScores, backendIdx, minScore,
+	)
+
+	return backends[backendIdx].GetUrl()
+}
+
+func (lb *peakEWMA) score(target *url.URL) time.Duration {
+	inFlight := lb.inFlight.count(target)
+
+	latency, ok := lb.latency.average(target)
+	if !ok {
+		// Probe unmeasured backends once, but not with a burst of requests
+		if inFlight == 0 {
+			return 0
+		}
+		latency = latencyPenalty
+	}
+
+	return latency * time.Duration(inFlight+1)
+}
+
+// ObserveLoadReport keeps the latency target reports about itself, which stands
+// in for its average until the proxy has measured one.
+func (lb *peakEWMA) ObserveLoadReport(target *url.URL, report loadreport.Report) {
+	lb.latency.observeReport(target, report)
+}
+
+func (lb *peakEWMA) ExportState() *WarmState {
+	return &WarmState{inFlight: &lb.inFlight, latency: lb.latency}
+}
+
+// ImportState keeps counting the requests in flight on the previous algorithm
+// and starts from the latencies it measured.
+func (lb *peakEWMA) ImportState(state *WarmState) {
+	lb.inFlight.adopt(state.inFlight)
+	lb.latency.seed(state.latency)
+}
@@ -0,0 +1,41 @@
+package algorithms
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// poolCache holds state an algorithm derives from the backend pool, e.g. a
+// hash ring, and rebuilds it the first time it is used after the pool changed.
+// Requests already holding the previous state keep using it.
+type poolCache[T any] struct {
+	current atomic.Pointer[poolCacheEntry[T]]
+	mutex   sync.Mutex
+}
+
+type poolCacheEntry[T any] struct {
+	version uint64
+	value   T
+}
+
+func (c *poolCache[T]) get(backends *pool.Pool, build func([]*pool.Backend) T) T {
+	if entry := c.current.Load(); entry != nil && entry.version == backends.Version() {
+		return entry.value
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Another request may have rebuilt it while this one waited
+	version, members := backends.Snapshot()
+	if entry := c.current.Load(); entry != nil && entry.version == version {
+		return entry.value
+	}
+
+	value := build(members)
+	c.current.Store(&poolCacheEntry[T]{version: version, value: value})
+
+	return value
+}
@@ -0,0 +1,121 @@
+package algorithms
+
+import (
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// powerOfTwoChoices samples two distinct backends at random and forwards to
+// the one with fewer requests in flight. It gets close to least connection
+// quality in O(1) without every request herding onto the same minimum.
+type powerOfTwoChoices struct {
+	pool       *pool.Pool
+	inFlight   inFlightTracker
+	proxyCache sync.Map
+}
+
+func NewPowerOfTwoChoicesAlg(backends *pool.Pool) (*powerOfTwoChoices, error) {
+	if backends == nil || backends.Len() == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	return &powerOfTwoChoices{
+		pool:       backends,
+		proxyCache: sync.Map{},
+	}, nil
+}
+
+func (lb *powerOfTwoChoices) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *powerOfTwoChoices) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	// The request is counted on its backend as part of the selection
+	return lb.inFlight.reserve(func() *url.URL { return lb.getNextBackend(filter) })
+}
+
+func (lb *powerOfTwoChoices) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	log.Println("-----------------------------------------------------------------")
+
+	// Log the next URL to which the request will be forwarded
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
+
+	// Create a reverse proxy for the next backend
+	proxy := lb.getOrCreateProxy(target)
+
+	// Serve the request using the reverse proxy
+	proxy.ServeHTTP(w, r)
+}
+
+func (lb *powerOfTwoChoices) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
+	key := target.String()
+	if proxy, ok := lb.proxyCache.Load(key); ok {
+		return proxy.(*httputil.ReverseProxy)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
+	lb.proxyCache.Store(key, proxy)
+
+	return proxy
+}
+
+func (lb *powerOfTwoChoices) getNextBackend(filter Filter) *url.URL {
+	backends := lb.pool.Backends()
+	usable := usableIndexes(backends, filter)
+	switch len(usable) {
+	case 0:
+		return nil
+	case 1:
+		// Only one usable backend server return it intermediately
+		return backends[usable[0]].GetUrl()
+	}
+
+	// Pick two distinct candidates, the second index skips over the first
+	firstPos := rand.IntN(len(usable))      //nolint:gosec
+	secondPos := rand.IntN(len(usable) - 1) //nolint:gosec
+	if secondPos >= firstPos {
+		secondPos++
+	}
+	firstIdx, secondIdx := usable[firstPos], usable[secondPos]
+
+	first, second := backends[firstIdx], backends[secondIdx]
+	firstConn, secondConn := lb.inFlight.count(first.GetUrl()), lb.inFlight.count(second.GetUrl())
+
+	// Prefer fewer connections, both candidates are random so a tie keeps the
+	// first one
+	selectedIdx := firstIdx
+	if secondConn < firstConn {
+		selectedIdx = secondIdx
+	}
+
+	log.Printf(
+		"[INFO] candidates: %d (connection: %d), %d (connection: %d), select: %d\n",
+		firstIdx, firstConn, secondIdx, secondConn, selectedIdx,
+	)
+
+	return backends[selectedIdx].GetUrl()
+}
+
+func (lb *powerOfTwoChoices) ExportState() *WarmState {
+	return &WarmState{inFlight: &lb.inFlight}
+}
+
+// ImportState keeps counting the requests in flight on the previous algorithm.
+func (lb *powerOfTwoChoices) ImportState(state *WarmState) {
+	lb.inFlight.adopt(state.inFlight)
+}
@@ -0,0 +1,102 @@
+package algorithms
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// ProxyObserver hears the outcome of requests proxied to a backend. Observers
+// travel with the request, so layers around the algorithms can watch every
+// backend without extra probe traffic.
+type ProxyObserver interface {
+	// ObserveResponse is called once the backend answered with status
+	ObserveResponse(target *url.URL, status int, elapsed time.Duration)
+
+	// ObserveError is called when the backend could not be reached or did
+	// not answer in time
+	ObserveError(target *url.URL, err error, elapsed time.Duration)
+}
+
+type proxyObserversKey struct{}
+
+type attachedObserver struct {
+	observer ProxyObserver
+	start    time.Time
+}
+
+// WithProxyObserver returns a copy of r whose outcome will be reported to
+// observer, elapsed times are measured from this call.
+func WithProxyObserver(r *http.Request, observer ProxyObserver) *http.Request {
+	attached, _ := r.Context().Value(proxyObserversKey{}).([]attachedObserver)
+
+	// Copy so requests derived from the same parent do not share observers
+	observers := make([]attachedObserver, 0, len(attached)+1)
+	observers = append(observers, attached...)
+	observers = append(observers, attachedObserver{observer: observer, start: time.Now()})
+
+	ctx := context.WithValue(r.Context(), proxyObserversKey{}, observers)
+	return r.WithContext(ctx)
+}
+
+// observeOutcome hooks proxy so the observers attached to each request hear
+// how it went. It chains the hooks already installed, so it is called last.
+func observeOutcome(proxy *httputil.ReverseProxy, target *url.URL) {
+	nextModify := proxy.ModifyResponse
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if nextModify != nil {
+			// A rejected response ends in the error handler, reported there
+			if err := nextModify(resp); err != nil {
+				return err
+			}
+		}
+
+		for _, attached := range attachedObservers(resp.Request) {
+			attached.observer.ObserveResponse(target, resp.StatusCode, time.Since(attached.start))
+		}
+		return nil
+	}
+
+	nextError := proxy.ErrorHandler
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		// A client giving up says nothing about the backend, a request canceled
+		// for another cause, e.g. a per try timeout, reports that cause
+		if cause := context.Cause(r.Context()); cause != nil && errors.Is(err, context.Canceled) {
+			err = cause
+		}
+
+		if !canceledWithoutCause(r, err) {
+			for _, attached := range attachedObservers(r) {
+				attached.observer.ObserveError(target, err, time.Since(attached.start))
+			}
+		}
+
+		if nextError != nil {
+			nextError(w, r, err)
+			return
+		}
+
+		log.Printf("[ERROR] proxy to %v failed: %v\n", target.String(), err)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+// canceledWithoutCause reports whether err only tells that r was canceled, by a
+// client giving up or a hedge losing its race, which says nothing about the
+// backend.
+func canceledWithoutCause(r *http.Request, err error) bool {
+	return errors.Is(err, context.Canceled) && errors.Is(context.Cause(r.Context()), context.Canceled)
+}
+
+func attachedObservers(r *http.Request) []attachedObserver {
+	if r == nil {
+		return nil
+	}
+
+	attached, _ := r.Context().Value(proxyObserversKey{}).([]attachedObserver)
+	return attached
+}
@@ -0,0 +1,97 @@
+package algorithms
+
+import (
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// randomAlg picks a backend uniformly at random. It is mostly useful as a
+// baseline, the seed makes a run reproducible.
+type randomAlg struct {
+	pool       *pool.Pool
+	rng        *rand.Rand
+	proxyCache sync.Map
+	mutex      sync.Mutex
+}
+
+func NewRandomAlg(backends *pool.Pool, seed uint64) (*randomAlg, error) {
+	if backends == nil || backends.Len() == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	return &randomAlg{
+		pool:       backends,
+		rng:        newSeededRand(seed),
+		proxyCache: sync.Map{},
+		mutex:      sync.Mutex{},
+	}, nil
+}
+
+func (lb *randomAlg) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *randomAlg) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	return lb.getNextBackend(filter), noRelease
+}
+
+func (lb *randomAlg) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	log.Println("-----------------------------------------------------------------")
+
+	// Log the next URL to which the request will be forwarded
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
+
+	// Create a reverse proxy for the next backend
+	proxy := lb.getOrCreateProxy(target)
+
+	// Serve the request using the reverse proxy
+	proxy.ServeHTTP(w, r)
+}
+
+func (lb *randomAlg) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
+	key := target.String()
+	if proxy, ok := lb.proxyCache.Load(key); ok {
+		return proxy.(*httputil.ReverseProxy)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
+	lb.proxyCache.Store(key, proxy)
+
+	return proxy
+}
+
+func (lb *randomAlg) getNextBackend(filter Filter) *url.URL {
+	backends := lb.pool.Backends()
+	usable := usableIndexes(backends, filter)
+	if len(usable) == 0 {
+		return nil
+	}
+
+	// The generator is not safe for concurrent use
+	lb.mutex.Lock()
+	idx := usable[lb.rng.IntN(len(usable))]
+	lb.mutex.Unlock()
+
+	return backends[idx].GetUrl()
+}
+
+// newSeededRand returns a deterministic generator, two generators built from
+// the same seed yield the same sequence.
+func newSeededRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)) //nolint:gosec
+}
@@ -0,0 +1,76 @@
+package algorithms
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+const testSeed = 42
+
+// selector is the part of an algorithm the selection tests drive.
+type selector interface {
+	NextBackend(r *http.Request, filter Filter) (*url.URL, func())
+}
+
+// pickIndexes returns the indexes in backends of the ones alg picks n times in
+// a row.
+func pickIndexes(t *testing.T, alg selector, backends *pool.Pool, n int) []int {
+	t.Helper()
+
+	indexes := make(map[string]int, backends.Len())
+	for idx, b := range backends.Backends() {
+		indexes[b.GetUrl().String()] = idx
+	}
+
+	sequence := make([]int, 0, n)
+	for range n {
+		target, release := alg.NextBackend(httptest.NewRequest("GET", "/", nil), nil)
+		release()
+		if target == nil {
+			t.Fatal("no backend picked")
+		}
+		sequence = append(sequence, indexes[target.String()])
+	}
+
+	return sequence
+}
+
+func TestRandomSequenceIsPinnedBySeed(t *testing.T) {
+	expected := []int{0, 0, 1, 2, 2, 2, 2, 1, 2, 2, 0, 2, 1, 2, 0, 0}
+
+	backends := newTestPool(t, 1, 1, 1, 1)
+	alg, err := NewRandomAlg(backends, testSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sequence := pickIndexes(t, alg, backends, len(expected))
+	if !slices.Equal(sequence, expected) {
+		t.Fatalf("seed %d picked %v, expected %v", testSeed, sequence, expected)
+	}
+}
+
+func TestRandomSameSeedSameSequence(t *testing.T) {
+	backends := newTestPool(t, 1, 1, 1, 1, 1)
+
+	sequences := make([][]int, 0, 3)
+	for _, seed := range []uint64{7, 7, 8} {
+		alg, err := NewRandomAlg(backends, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sequences = append(sequences, pickIndexes(t, alg, backends, 64))
+	}
+
+	if !slices.Equal(sequences[0], sequences[1]) {
+		t.Fatalf("same seed picked %v then %v", sequences[0], sequences[1])
+	}
+	if slices.Equal(sequences[0], sequences[2]) {
+		t.Fatalf("seeds 7 and 8 both picked %v", sequences[0])
+	}
+}
@@ -0,0 +1,124 @@
+package algorithms
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// rendezvousHash implements weighted highest random weight hashing. The client
+// key is hashed against every backend URL and the best score wins, so only the
+// keys owned by a removed backend move and no ring has to be maintained.
+type rendezvousHash struct {
+	pool       *pool.Pool
+	key        KeyExtractor
+	proxyCache sync.Map
+}
+
+func NewRendezvousHashAlg(
+	backends *pool.Pool, key KeyExtractor,
+) (*rendezvousHash, error) {
+	if backends == nil || backends.Len() == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	rh := &rendezvousHash{
+		pool:       backends,
+		key:        key,
+		proxyCache: sync.Map{},
+	}
+
+	return rh, nil
+}
+
+func (lb *rendezvousHash) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *rendezvousHash) NextBackend(r *http.Request, filter Filter) (*url.URL, func()) {
+	key := affinityKey(r, lb.key)
+
+	nextUrl := lb.getNextBackend(key, filter)
+	if nextUrl == nil {
+		return nil, noRelease
+	}
+
+	// Log the next URL to which the request will be forwarded
+	log.Println("---------------------------------------------------------")
+	log.Printf(
+		"[INFO] key: %s -> load balancer forwarding request to: %v\n",
+		key, nextUrl.String(),
+	)
+
+	return nextUrl, noRelease
+}
+
+func (lb *rendezvousHash) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	// Create a reverse proxy for the next backend
+	proxy := lb.getOrCreateProxy(target)
+
+	// Serve the request using the reverse proxy
+	proxy.ServeHTTP(w, r)
+}
+
+func (lb *rendezvousHash) getNextBackend(key string, filter Filter) *url.URL {
+	backends := lb.pool.Backends()
+	bestIdx := -1
+	bestScore := math.Inf(-1)
+
+	// Skipping an unusable backend only moves its own keys, to their runner-up
+	for idx, b := range backends {
+		if !filter.Allows(b.GetUrl()) {
+			continue
+		}
+
+		score := lb.score(key, b)
+		if bestIdx == -1 || score > bestScore {
+			bestScore = score
+			bestIdx = idx
+		}
+	}
+
+	if bestIdx == -1 {
+		return nil
+	}
+
+	return backends[bestIdx].GetUrl()
+}
+
+// score uses the logarithmic method: with h uniform in (0, 1) the value
+// -weight / ln(h) makes each backend win with probability weight / totalWeight.
+func (lb *rendezvousHash) score(key string, b *pool.Backend) float64 {
+	hash := hashKey(key + "@" + b.GetUrl().String())
+
+	// Keep the top 53 bits and shift into the open interval (0, 1)
+	h := (float64(hash>>11) + 0.5) / (1 << 53)
+
+	return -float64(max(b.GetWeight(), 1)) / math.Log(h)
+}
+
+func (lb *rendezvousHash) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
+	key := target.String()
+	if proxy, ok := lb.proxyCache.Load(key); ok {
+		return proxy.(*httputil.ReverseProxy)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
+	lb.proxyCache.Store(key, proxy)
+
+	return proxy
+}
@@ -2,34 +2,32 @@ package algorithms
 
 import (
 	"log"
+	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"sync"
 
 	"github.com/DucTran999/load-balancing-algo/internal/errs"
-	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/DucTran999/load-balancing-algo/pkg/loadreport"
 )
 
+// resourceBaseLoadAlg picks the backend reporting the lowest CPU utilization in
+// the load report attached to its responses.
 type resourceBaseLoadAlg struct {
-	backends   []*backend.SimpleHTTPServer
+	pool       *pool.Pool
+	reports    loadReportStore
 	proxyCache sync.Map
 }
 
-func NewResourceBaseLoadAlg(targets []*backend.SimpleHTTPServer) (*resourceBaseLoadAlg, error) {
-	if len(targets) == 0 {
+func NewResourceBaseLoadAlg(backends *pool.Pool) (*resourceBaseLoadAlg, error) {
+	if backends == nil || backends.Len() == 0 {
 		return nil, errs.ErrNoTargetServersFound
 	}
 
-	// Validate backend URLs
-	for _, target := range targets {
-		if target.GetUrl() == nil {
-			return nil, errs.ErrInvalidBackendUrl
-		}
-	}
-
 	rbl := &resourceBaseLoadAlg{
-		backends:   targets,
+		pool:       backends,
 		proxyCache: sync.Map{},
 	}
 
@@ -37,13 +35,26 @@ func NewResourceBaseLoadAlg(targets []*backend.SimpleHTTPServer) (*resourceBaseL
 }
 
 func (lb *resourceBaseLoadAlg) ForwardRequest(w http.ResponseWriter, r *http.Request) {
-	nextUrl := lb.getNextBackend()
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
 
+func (lb *resourceBaseLoadAlg) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	return lb.getNextBackend(filter), noRelease
+}
+
+func (lb *resourceBaseLoadAlg) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
 	// Log the next URL to which the request will be forwarded
-	log.Printf("[INFO] load balancer forwarding request to: %v\n", nextUrl.String())
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
 
 	// Create a reverse proxy for the next backend
-	proxy := lb.getOrCreateProxy(nextUrl)
+	proxy := lb.getOrCreateProxy(target)
 
 	// Serve the request using the reverse proxy
 	proxy.ServeHTTP(w, r)
@@ -56,37 +67,67 @@ func (lb *resourceBaseLoadAlg) getOrCreateProxy(target *url.URL) *httputil.Rever
 	}
 
 	proxy := httputil.NewSingleHostReverseProxy(target)
+	lb.reports.instrument(proxy, target)
+	observeOutcome(proxy, target)
 	lb.proxyCache.Store(key, proxy)
 
 	return proxy
 }
 
-func (lb *resourceBaseLoadAlg) getNextBackend() *url.URL {
-	// Only one backend server return it intermediately
-	if len(lb.backends) == 1 {
-		return lb.backends[0].GetUrl()
-	}
-
-	// Lookup the backends got lowest cpu load
-	minCPULoad := lb.backends[0].GetCPULoad()
-	backendIdx := 0
-	backendCPUs := []float64{minCPULoad}
-
-	for idx := 1; idx < len(lb.backends); idx++ {
-		backend := lb.backends[idx]
-		backendCPUs = append(backendCPUs, backend.GetCPULoad())
+func (lb *resourceBaseLoadAlg) getNextBackend(filter Filter) *url.URL {
+	backends := lb.pool.Backends()
+	// Lookup the usable backend got lowest cpu load
+	var minCPULoad float64
+	backendIdx := -1
+	backendCPUs := make([]float64, 0, len(backends))
+
+	for idx := range backends {
+		cpuLoad := lb.cpuLoad(backends[idx].GetUrl())
+		backendCPUs = append(backendCPUs, cpuLoad)
+		if !filter.Allows(backends[idx].GetUrl()) {
+			continue
+		}
 
-		if minCPULoad > lb.backends[idx].GetCPULoad() {
-			minCPULoad = backend.GetCPULoad()
+		if backendIdx == -1 || minCPULoad > cpuLoad {
+			minCPULoad = cpuLoad
 			backendIdx = idx
 		}
 	}
 
+	if backendIdx == -1 {
+		return nil
+	}
+
 	log.Println("----------------------------------------------------")
 	log.Printf(
 		"[INFO] backend connections: %v, select: %d, CPU load: %.2f \n",
 		backendCPUs, backendIdx, minCPULoad,
 	)
 
-	return lb.backends[backendIdx].GetUrl()
+	return backends[backendIdx].GetUrl()
+}
+
+// cpuLoad returns the reported CPU load of target in percent. A backend that
+// has not reported yet counts as idle so it receives a request and reports.
+func (lb *resourceBaseLoadAlg) cpuLoad(target *url.URL) float64 {
+	report, _, ok := lb.reports.latest(target)
+	if !ok {
+		return 0
+	}
+
+	return math.Round(report.CPUUtilization*10000) / 100
+}
+
+// ObserveLoadReport records a report polled out of band from target.
+func (lb *resourceBaseLoadAlg) ObserveLoadReport(target *url.URL, report loadreport.Report) {
+	lb.reports.record(target, report)
+}
+
+func (lb *resourceBaseLoadAlg) ExportState() *WarmState {
+	return &WarmState{reports: &lb.reports}
+}
+
+// ImportState starts from the load reports the previous algorithm received.
+func (lb *resourceBaseLoadAlg) ImportState(state *WarmState) {
+	lb.reports.seed(state.reports)
 }
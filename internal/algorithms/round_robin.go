@@ -9,43 +9,49 @@ import (
 	"sync/atomic"
 
 	"github.com/DucTran999/load-balancing-algo/internal/errs"
-	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
 )
 
 type roundRobin struct {
-	backends   []*backend.SimpleHTTPServer
+	pool       *pool.Pool
 	counter    uint64
 	proxyCache sync.Map
 }
 
-func NewRoundRobinAlg(targets []*backend.SimpleHTTPServer) (*roundRobin, error) {
-	if len(targets) == 0 {
+func NewRoundRobinAlg(backends *pool.Pool) (*roundRobin, error) {
+	if backends == nil || backends.Len() == 0 {
 		return nil, errs.ErrNoTargetServersFound
 	}
 
-	// Validate backend URLs
-	for _, target := range targets {
-		if target.GetUrl() == nil {
-			return nil, errs.ErrInvalidBackendUrl
-		}
-	}
-
 	return &roundRobin{
-		backends:   targets,
+		pool:       backends,
 		proxyCache: sync.Map{},
 	}, nil
 }
 
 func (lb *roundRobin) ForwardRequest(w http.ResponseWriter, r *http.Request) {
-	nextUrl := lb.getNextBackend()
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *roundRobin) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	return lb.getNextBackend(filter), noRelease
+}
 
+func (lb *roundRobin) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
 	log.Println("-----------------------------------------------------------------")
 
 	// Log the next URL to which the request will be forwarded
-	log.Printf("[INFO] load balancer forwarding request to: %v\n", nextUrl.String())
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
 
 	// Create a reverse proxy for the next backend
-	proxy := lb.getOrCreateProxy(&nextUrl)
+	proxy := lb.getOrCreateProxy(target)
 
 	// Serve the request using the reverse proxy
 	proxy.ServeHTTP(w, r)
@@ -58,15 +64,23 @@ func (lb *roundRobin) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
 	}
 
 	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
 	lb.proxyCache.Store(key, proxy)
 
 	return proxy
 }
 
-func (lb *roundRobin) getNextBackend() url.URL {
-	idx := atomic.AddUint64(&lb.counter, 1)
+func (lb *roundRobin) getNextBackend(filter Filter) *url.URL {
+	backends := lb.pool.Backends()
+	// Step over unusable backends, at most one full turn
+	for range len(backends) {
+		idx := atomic.AddUint64(&lb.counter, 1)
 
-	next := lb.backends[idx%uint64(len(lb.backends))]
+		next := backends[idx%uint64(len(backends))]
+		if filter.Allows(next.GetUrl()) {
+			return next.GetUrl()
+		}
+	}
 
-	return *next.GetUrl()
+	return nil
 }
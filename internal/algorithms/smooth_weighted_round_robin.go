@@ -0,0 +1,212 @@
+package algorithms
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+type smoothWeightedPeer struct {
+	backend         *pool.Backend
+	weight          int
+	currentWeight   int
+	effectiveWeight int
+}
+
+// smoothWeightedRoundRobin is the nginx flavor of weighted round-robin. It
+// keeps the ratio set by the weights but interleaves the backends
+// (a, a, b, a, c, a, ...) instead of sending weight requests in a row.
+type smoothWeightedRoundRobin struct {
+	pool       *pool.Pool
+	version    uint64
+	peers      []*smoothWeightedPeer
+	proxyCache sync.Map
+	mutex      sync.Mutex
+}
+
+func NewSmoothWeightedRoundRobinAlg(
+	backends *pool.Pool,
+) (*smoothWeightedRoundRobin, error) {
+	if backends == nil || backends.Len() == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	swrr := &smoothWeightedRoundRobin{
+		pool:       backends,
+		proxyCache: sync.Map{},
+		mutex:      sync.Mutex{},
+	}
+
+	swrr.mutex.Lock()
+	swrr.syncPeers(backends.Snapshot())
+	swrr.mutex.Unlock()
+
+	return swrr, nil
+}
+
+func (lb *smoothWeightedRoundRobin) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *smoothWeightedRoundRobin) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	return lb.getNextBackend(filter), noRelease
+}
+
+func (lb *smoothWeightedRoundRobin) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	log.Println("-----------------------------------------------------------------")
+
+	// Log the next URL to which the request will be forwarded
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
+
+	// Create a reverse proxy for the next backend
+	proxy := lb.getOrCreateProxy(target)
+
+	// Serve the request using the reverse proxy
+	proxy.ServeHTTP(w, r)
+}
+
+func (lb *smoothWeightedRoundRobin) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
+	key := target.String()
+	if proxy, ok := lb.proxyCache.Load(key); ok {
+		return proxy.(*httputil.ReverseProxy)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("[ERROR] proxy to %v failed: %v\n", target.String(), err)
+
+		// A request canceled without a cause was given up by the client or
+		// lost a hedging race, the backend is not to blame
+		if !canceledWithoutCause(r, err) {
+			lb.markFailed(key)
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	observeOutcome(proxy, target)
+	lb.proxyCache.Store(key, proxy)
+
+	return proxy
+}
+
+// getNextBackend raises every current weight by its effective weight, picks the
+// highest and lowers the winner by the total, which spreads the heavy backends
+// evenly across the cycle.
+func (lb *smoothWeightedRoundRobin) getNextBackend(filter Filter) *url.URL {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	if version, backends := lb.pool.Snapshot(); version != lb.version {
+		lb.syncPeers(version, backends)
+	}
+
+	var best *smoothWeightedPeer
+	total := 0
+
+	for _, peer := range lb.peers {
+		// Unusable peers sit the round out, as nginx does with down peers
+		if !filter.Allows(peer.backend.GetUrl()) {
+			continue
+		}
+
+		peer.currentWeight += peer.effectiveWeight
+		total += peer.effectiveWeight
+
+		// Recover a failed peer step by step towards its configured weight
+		if peer.effectiveWeight < peer.weight {
+			peer.effectiveWeight++
+		}
+
+		if best == nil || peer.currentWeight > best.currentWeight {
+			best = peer
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+
+	best.currentWeight -= total
+
+	return best.backend.GetUrl()
+}
+
+// syncPeers rebuilds the peers from the pool members. Peers staying in the pool
+// keep their current weight so the interleaving goes on where it was, new ones
+// start from zero. Callers hold the mutex.
+func (lb *smoothWeightedRoundRobin) syncPeers(version uint64, backends []*pool.Backend) {
+	previous := make(map[*pool.Backend]*smoothWeightedPeer, len(lb.peers))
+	for _, peer := range lb.peers {
+		previous[peer.backend] = peer
+	}
+
+	peers := make([]*smoothWeightedPeer, 0, len(backends))
+	for _, b := range backends {
+		weight := max(b.GetWeight(), 1)
+
+		peer, ok := previous[b]
+		if !ok {
+			peer = &smoothWeightedPeer{backend: b, effectiveWeight: weight}
+		}
+
+		// A lower weight takes effect at once, a higher one is reached step
+		// by step like after a failure
+		peer.weight = weight
+		peer.effectiveWeight = min(peer.effectiveWeight, weight)
+		peers = append(peers, peer)
+	}
+
+	lb.version = version
+	lb.peers = peers
+}
+
+// markFailed lowers the effective weight of a backend the proxy could not
+// reach, so it receives less traffic until it recovers.
+func (lb *smoothWeightedRoundRobin) markFailed(key string) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	for _, peer := range lb.peers {
+		if peer.backend.GetUrl().String() == key {
+			peer.effectiveWeight = max(peer.effectiveWeight-peer.weight, 0)
+			return
+		}
+	}
+}
+
+func (lb *smoothWeightedRoundRobin) ExportState() *WarmState {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	peers := make(map[string]smoothWeightedPeer, len(lb.peers))
+	for _, peer := range lb.peers {
+		peers[peer.backend.GetUrl().String()] = *peer
+	}
+
+	return &WarmState{peers: peers}
+}
+
+// ImportState goes on with the interleaving of the previous smooth weighted
+// round-robin instead of restarting the cycle.
+func (lb *smoothWeightedRoundRobin) ImportState(state *WarmState) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	for _, peer := range lb.peers {
+		if previous, ok := state.peers[peer.backend.GetUrl().String()]; ok {
+			peer.currentWeight = previous.currentWeight
+			peer.effectiveWeight = min(previous.effectiveWeight, peer.weight)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package algorithms
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+// pickSequence returns the indexes of the backends swrr picks n times in a
+// row.
+func pickSequence(t *testing.T, weights []int, n int) []int {
+	t.Helper()
+
+	backends := newTestPool(t, weights...)
+	swrr, err := NewSmoothWeightedRoundRobinAlg(backends)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pickIndexes(t, swrr, backends, n)
+}
+
+func TestSmoothWeightedRoundRobinInterleaves(t *testing.T) {
+	// The nginx sequence for weights a=5, b=1, c=1
+	expected := []int{0, 0, 1, 0, 2, 0, 0}
+
+	sequence := pickSequence(t, []int{5, 1, 1}, 2*len(expected))
+	for idx, backendIdx := range sequence {
+		if backendIdx != expected[idx%len(expected)] {
+			t.Fatalf("sequence %v, expected %v repeated", sequence, expected)
+		}
+	}
+}
+
+func TestSmoothWeightedRoundRobinDistribution(t *testing.T) {
+	const cycles = 50
+
+	tests := []struct {
+		name    string
+		weights []int
+	}{
+		{name: "one heavy backend", weights: []int{5, 1, 1}},
+		{name: "mixed weights", weights: []int{4, 3, 2, 1}},
+		{name: "equal weights", weights: []int{2, 2, 2}},
+		{name: "very heavy backend", weights: []int{10, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, heaviest := 0, 0
+			for _, weight := range tt.weights {
+				total += weight
+				heaviest = max(heaviest, weight)
+			}
+
+			sequence := pickSequence(t, tt.weights, cycles*total)
+
+			// Every backend gets exactly its share over whole cycles
+			counts := make([]int, len(tt.weights))
+			for _, backendIdx := range sequence {
+				counts[backendIdx]++
+			}
+			for idx, weight := range tt.weights {
+				if counts[idx] != cycles*weight {
+					t.Errorf("backend %d picked %d times, expected %d", idx, counts[idx], cycles*weight)
+				}
+			}
+
+			// No backend is picked in a long run, the heaviest one at most
+			// ceil(heaviest / others) + 1 times in a row
+			others := total - heaviest
+			maxBurst := (heaviest+others-1)/others + 1
+
+			burst := 1
+			for idx := 1; idx < len(sequence); idx++ {
+				if sequence[idx] != sequence[idx-1] {
+					burst = 1
+					continue
+				}
+
+				burst++
+				if burst > maxBurst {
+					t.Fatalf("backend %d picked %d times in a row, at most %d expected", sequence[idx], burst, maxBurst)
+				}
+			}
+		})
+	}
+}
+
+func TestSmoothWeightedRoundRobinIgnoresCanceledRequests(t *testing.T) {
+	backends := newTestPool(t, 3, 1)
+	swrr, err := NewSmoothWeightedRoundRobinAlg(backends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pickIndexes(t, swrr, backends, 1)
+
+	target := backends.Backends()[0].GetUrl()
+	proxy := swrr.getOrCreateProxy(target)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+
+	proxy.ErrorHandler(httptest.NewRecorder(), r, context.Canceled)
+	if weight := swrr.peers[0].effectiveWeight; weight != 3 {
+		t.Fatalf("effective weight %d after a canceled request, expected 3", weight)
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	proxy.ErrorHandler(httptest.NewRecorder(), r, errors.New("connection refused"))
+	if weight := swrr.peers[0].effectiveWeight; weight != 0 {
+		t.Fatalf("effective weight %d after a failed request, expected 0", weight)
+	}
+}
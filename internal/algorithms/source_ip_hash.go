@@ -3,35 +3,31 @@ package algorithms
 import (
 	"hash/fnv"
 	"log"
-	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"sync"
 
 	"github.com/DucTran999/load-balancing-algo/internal/errs"
-	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
 )
 
 type sourceIPHash struct {
-	backends   []*backend.SimpleHTTPServer
+	pool       *pool.Pool
+	key        KeyExtractor
 	proxyCache sync.Map
 }
 
-func NewSourceIPHashAlgorithm(targets []*backend.SimpleHTTPServer) (*sourceIPHash, error) {
-	if len(targets) == 0 {
+func NewSourceIPHashAlgorithm(
+	backends *pool.Pool, key KeyExtractor,
+) (*sourceIPHash, error) {
+	if backends == nil || backends.Len() == 0 {
 		return nil, errs.ErrNoTargetServersFound
 	}
 
-	// Validate backend URLs
-	for _, target := range targets {
-		if target.GetUrl() == nil {
-			return nil, errs.ErrInvalidBackendUrl
-		}
-	}
-
 	sih := &sourceIPHash{
-		backends:   targets,
+		pool:       backends,
+		key:        key,
 		proxyCache: sync.Map{},
 	}
 
@@ -39,41 +35,55 @@ func NewSourceIPHashAlgorithm(targets []*backend.SimpleHTTPServer) (*sourceIPHas
 }
 
 func (lb *sourceIPHash) ForwardRequest(w http.ResponseWriter, r *http.Request) {
-	ip := lb.getClientIP(r)
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *sourceIPHash) NextBackend(r *http.Request, filter Filter) (*url.URL, func()) {
+	key := affinityKey(r, lb.key)
 
-	nextUrl := lb.getNextBackend(ip)
+	nextUrl := lb.getNextBackend(key, filter)
+	if nextUrl == nil {
+		return nil, noRelease
+	}
 
 	// Log the next URL to which the request will be forwarded
 	log.Println("---------------------------------------------------------")
 	log.Printf(
-		"[INFO] source ip: %s -> load balancer forwarding request to: %v\n",
-		ip, nextUrl.String(),
+		"[INFO] key: %s -> load balancer forwarding request to: %v\n",
+		key, nextUrl.String(),
 	)
 
+	return nextUrl, noRelease
+}
+
+func (lb *sourceIPHash) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
 	// Create a reverse proxy for the next backend
-	proxy := lb.getOrCreateProxy(&nextUrl)
+	proxy := lb.getOrCreateProxy(target)
 
 	// Serve the request using the reverse proxy
 	proxy.ServeHTTP(w, r)
 }
 
-func (lb *sourceIPHash) getClientIP(r *http.Request) string {
-	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
-		return ip
-	}
+func (lb *sourceIPHash) getNextBackend(key string, filter Filter) *url.URL {
+	backends := lb.pool.Backends()
+	idx := lb.simpleHash(key, len(backends))
 
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		log.Printf("[ERROR] failed to get client ip")
-		host = r.RemoteAddr // keep real IP
+	// Probe the following buckets when the hashed one is unusable
+	for range len(backends) {
+		if filter.Allows(backends[idx].GetUrl()) {
+			return backends[idx].GetUrl()
+		}
+		idx = (idx + 1) % len(backends)
 	}
 
-	return host
-}
-
-func (lb *sourceIPHash) getNextBackend(sourceIP string) url.URL {
-	idx := lb.simpleHash(sourceIP, len(lb.backends))
-	return *lb.backends[idx].GetUrl()
+	return nil
 }
 
 func (lb *sourceIPHash) simpleHash(s string, buckets int) int {
@@ -90,6 +100,7 @@ func (lb *sourceIPHash) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy
 	}
 
 	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
 	lb.proxyCache.Store(key, proxy)
 
 	return proxy
@@ -0,0 +1,19 @@
+package algorithms
+
+// WarmState is what an algorithm learned about the backends. The load balancer
+// hands it to the algorithm replacing it, which then does not start from
+// scratch.
+type WarmState struct {
+	inFlight *inFlightTracker
+	latency  *latencyTracker
+	reports  *loadReportStore
+	peers    map[string]smoothWeightedPeer
+}
+
+// WarmStarter is implemented by the algorithms keeping state worth handing
+// over when another algorithm is switched in. Each takes what it understands
+// from the state and ignores the rest.
+type WarmStarter interface {
+	ExportState() *WarmState
+	ImportState(state *WarmState)
+}
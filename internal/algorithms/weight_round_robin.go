@@ -5,54 +5,65 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"slices"
 	"sort"
 	"sync"
 
 	"github.com/DucTran999/load-balancing-algo/internal/errs"
-	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
 )
 
 type weightedRoundRobin struct {
-	backends      []*backend.SimpleHTTPServer
+	pool          *pool.Pool
+	version       uint64
+	backends      []*pool.Backend
 	currentWeight int
 	currentIndex  int
 	proxyCache    sync.Map
 	mutex         sync.Mutex
 }
 
-func NewWeightedRoundRobinAlg(targets []*backend.SimpleHTTPServer) (*weightedRoundRobin, error) {
-	if len(targets) == 0 {
+func NewWeightedRoundRobinAlg(backends *pool.Pool) (*weightedRoundRobin, error) {
+	if backends == nil || backends.Len() == 0 {
 		return nil, errs.ErrNoTargetServersFound
 	}
 
-	// Validate backend URLs
-	for _, target := range targets {
-		if target.GetUrl() == nil {
-			return nil, errs.ErrInvalidBackendUrl
-		}
-	}
-
 	wrr := &weightedRoundRobin{
-		backends:   targets,
+		pool:       backends,
 		proxyCache: sync.Map{},
 		mutex:      sync.Mutex{},
 	}
 
-	wrr.electInitialBackend()
+	wrr.mutex.Lock()
+	wrr.electInitialBackend(backends.Snapshot())
+	wrr.mutex.Unlock()
 
 	return wrr, nil
 }
 
 func (lb *weightedRoundRobin) ForwardRequest(w http.ResponseWriter, r *http.Request) {
-	nextUrl := lb.getNextBackend()
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
 
+func (lb *weightedRoundRobin) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	return lb.getNextBackend(filter), noRelease
+}
+
+func (lb *weightedRoundRobin) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
 	log.Println("-----------------------------------------------------------------")
 
 	// Log the next URL to which the request will be forwarded
-	log.Printf("[INFO] load balancer forwarding request to: %v\n", nextUrl.String())
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
 
 	// Create a reverse proxy for the next backend
-	proxy := lb.getOrCreateProxy(&nextUrl)
+	proxy := lb.getOrCreateProxy(target)
 
 	// Serve the request using the reverse proxy
 	proxy.ServeHTTP(w, r)
@@ -65,28 +76,56 @@ func (lb *weightedRoundRobin) getOrCreateProxy(target *url.URL) *httputil.Revers
 	}
 
 	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
 	lb.proxyCache.Store(key, proxy)
 
 	return proxy
 }
 
-func (lb *weightedRoundRobin) getNextBackend() url.URL {
+func (lb *weightedRoundRobin) getNextBackend(filter Filter) *url.URL {
 	lb.mutex.Lock()
 	defer lb.mutex.Unlock()
 
+	// Start a new cycle over the members when the pool changed
+	if version, backends := lb.pool.Snapshot(); version != lb.version {
+		lb.electInitialBackend(version, backends)
+	}
+
+	// The current backend may have become unusable in the middle of its turn
+	if lb.currentWeight > 0 && !filter.Allows(lb.backends[lb.currentIndex].GetUrl()) {
+		lb.currentWeight = 0
+	}
+
 	if lb.currentWeight == 0 {
-		lb.currentIndex = lb.calculateNextIndex()
+		if !lb.moveToNextUsable(filter) {
+			return nil
+		}
 		lb.currentWeight = lb.backends[lb.currentIndex].GetWeight()
 	}
 
 	lb.currentWeight--
 	nextBackend := lb.backends[lb.currentIndex]
-	return *nextBackend.GetUrl()
+	return nextBackend.GetUrl()
 }
 
-func (lb *weightedRoundRobin) electInitialBackend() {
-	lb.mutex.Lock()
-	defer lb.mutex.Unlock()
+// moveToNextUsable advances the current index to the next backend accepted by
+// filter, it reports false when a full turn finds none.
+func (lb *weightedRoundRobin) moveToNextUsable(filter Filter) bool {
+	for range len(lb.backends) {
+		lb.currentIndex = lb.calculateNextIndex()
+		if filter.Allows(lb.backends[lb.currentIndex].GetUrl()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// electInitialBackend orders a copy of the pool members. Callers hold the
+// mutex.
+func (lb *weightedRoundRobin) electInitialBackend(version uint64, backends []*pool.Backend) {
+	lb.version = version
+	lb.backends = slices.Clone(backends)
 
 	// Sort backends by weight in descending order
 	sort.SliceStable(lb.backends, func(i, j int) bool {
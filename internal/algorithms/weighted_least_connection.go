@@ -0,0 +1,138 @@
+package algorithms
+
+import (
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// weightedLeastConnection picks the backend with the fewest requests in flight
+// per unit of weight, so bigger machines take proportionally more concurrent
+// work.
+type weightedLeastConnection struct {
+	pool       *pool.Pool
+	inFlight   inFlightTracker
+	proxyCache sync.Map
+}
+
+func NewWeightedLeastConnectionAlg(
+	backends *pool.Pool,
+) (*weightedLeastConnection, error) {
+	if backends == nil || backends.Len() == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	return &weightedLeastConnection{
+		pool:       backends,
+		proxyCache: sync.Map{},
+	}, nil
+}
+
+func (lb *weightedLeastConnection) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *weightedLeastConnection) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	// The request is counted on its backend as part of the selection
+	return lb.inFlight.reserve(func() *url.URL { return lb.getNextBackend(filter) })
+}
+
+func (lb *weightedLeastConnection) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	log.Println("-----------------------------------------------------------------")
+
+	// Log the next URL to which the request will be forwarded
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
+
+	// Create a reverse proxy for the next backend
+	proxy := lb.getOrCreateProxy(target)
+
+	// Serve the request using the reverse proxy
+	proxy.ServeHTTP(w, r)
+}
+
+func (lb *weightedLeastConnection) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
+	key := target.String()
+	if proxy, ok := lb.proxyCache.Load(key); ok {
+		return proxy.(*httputil.ReverseProxy)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
+	lb.proxyCache.Store(key, proxy)
+
+	return proxy
+}
+
+func (lb *weightedLeastConnection) getNextBackend(filter Filter) *url.URL {
+	backends := lb.pool.Backends()
+	backendIdx := -1
+	ties := 0
+	backendConnections := make([]int64, len(backends))
+
+	for idx, b := range backends {
+		backendConnections[idx] = lb.inFlight.count(b.GetUrl())
+		if !filter.Allows(b.GetUrl()) {
+			continue
+		}
+
+		if backendIdx == -1 {
+			backendIdx = idx
+			ties = 1
+			continue
+		}
+
+		// Compare connections / weight without dividing:
+		// c_i / w_i < c_best / w_best  <=>  c_i * w_best < c_best * w_i
+		lhs := backendConnections[idx] * lb.weight(backends[backendIdx])
+		rhs := backendConnections[backendIdx] * lb.weight(b)
+
+		switch {
+		case lhs < rhs:
+			backendIdx = idx
+			ties = 1
+		case lhs == rhs:
+			// Reservoir sampling keeps every tied backend equally likely
+			ties++
+			if rand.IntN(ties) == 0 { //nolint:gosec
+				backendIdx = idx
+			}
+		}
+	}
+
+	if backendIdx == -1 {
+		return nil
+	}
+
+	log.Printf(
+		"[INFO] backend connections: %v, select: %d, connection: %d, weight: %d\n",
+		backendConnections, backendIdx, backendConnections[backendIdx], lb.weight(backends[backendIdx]),
+	)
+
+	return backends[backendIdx].GetUrl()
+}
+
+func (lb *weightedLeastConnection) weight(b *pool.Backend) int64 {
+	return int64(max(b.GetWeight(), 1))
+}
+
+func (lb *weightedLeastConnection) ExportState() *WarmState {
+	return &WarmState{inFlight: &lb.inFlight}
+}
+
+// ImportState keeps counting the requests in flight on the previous algorithm.
+func (lb *weightedLeastConnection) ImportState(state *WarmState) {
+	lb.inFlight.adopt(state.inFlight)
+}
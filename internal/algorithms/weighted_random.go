@@ -0,0 +1,186 @@
+package algorithms
+
+import (
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// weightedRandom picks a backend at random with a probability proportional to
+// its weight. An alias table built with Vose's method whenever the pool changes
+// makes every pick O(1) whatever the size of the pool.
+type weightedRandom struct {
+	pool       *pool.Pool
+	table      poolCache[*aliasTable]
+	rng        *rand.Rand
+	proxyCache sync.Map
+	mutex      sync.Mutex
+}
+
+// aliasTable holds one column per backend the table was built from.
+type aliasTable struct {
+	backends []*pool.Backend
+	prob     []float64
+	alias    []int
+}
+
+func NewWeightedRandomAlg(backends *pool.Pool, seed uint64) (*weightedRandom, error) {
+	if backends == nil || backends.Len() == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	wr := &weightedRandom{
+		pool:       backends,
+		rng:        newSeededRand(seed),
+		proxyCache: sync.Map{},
+		mutex:      sync.Mutex{},
+	}
+
+	return wr, nil
+}
+
+func (lb *weightedRandom) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *weightedRandom) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	return lb.getNextBackend(filter), noRelease
+}
+
+func (lb *weightedRandom) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	log.Println("-----------------------------------------------------------------")
+
+	// Log the next URL to which the request will be forwarded
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
+
+	// Create a reverse proxy for the next backend
+	proxy := lb.getOrCreateProxy(target)
+
+	// Serve the request using the reverse proxy
+	proxy.ServeHTTP(w, r)
+}
+
+func (lb *weightedRandom) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
+	key := target.String()
+	if proxy, ok := lb.proxyCache.Load(key); ok {
+		return proxy.(*httputil.ReverseProxy)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	observeOutcome(proxy, target)
+	lb.proxyCache.Store(key, proxy)
+
+	return proxy
+}
+
+// getNextBackend rolls a fair die to pick a column of the alias table, then a
+// biased coin to keep the column or take its alias.
+func (lb *weightedRandom) getNextBackend(filter Filter) *url.URL {
+	table := lb.table.get(lb.pool, buildAliasTable)
+
+	// The generator is not safe for concurrent use
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+
+	idx := lb.rng.IntN(len(table.backends))
+	if lb.rng.Float64() >= table.prob[idx] {
+		idx = table.alias[idx]
+	}
+
+	if filter.Allows(table.backends[idx].GetUrl()) {
+		return table.backends[idx].GetUrl()
+	}
+
+	return lb.pickUsable(table.backends, filter)
+}
+
+// pickUsable draws among the usable backends only, weighted by a linear scan
+// as the alias table covers the whole pool. Callers hold the mutex.
+func (lb *weightedRandom) pickUsable(backends []*pool.Backend, filter Filter) *url.URL {
+	usable := usableIndexes(backends, filter)
+	if len(usable) == 0 {
+		return nil
+	}
+
+	totalWeight := 0
+	for _, idx := range usable {
+		totalWeight += max(backends[idx].GetWeight(), 1)
+	}
+
+	roll := lb.rng.IntN(totalWeight)
+	for _, idx := range usable {
+		roll -= max(backends[idx].GetWeight(), 1)
+		if roll < 0 {
+			return backends[idx].GetUrl()
+		}
+	}
+
+	return backends[usable[len(usable)-1]].GetUrl()
+}
+
+// buildAliasTable splits the scaled weights into n columns of height 1, each
+// holding at most two backends: the column owner and its alias.
+func buildAliasTable(backends []*pool.Backend) *aliasTable {
+	n := len(backends)
+	table := &aliasTable{
+		backends: backends,
+		prob:     make([]float64, n),
+		alias:    make([]int, n),
+	}
+
+	totalWeight := 0
+	for _, b := range backends {
+		totalWeight += max(b.GetWeight(), 1)
+	}
+
+	// Scale weights so the average column is exactly 1
+	scaled := make([]float64, n)
+	var small, large []int
+	for idx, b := range backends {
+		scaled[idx] = float64(max(b.GetWeight(), 1)*n) / float64(totalWeight)
+		if scaled[idx] < 1 {
+			small = append(small, idx)
+		} else {
+			large = append(large, idx)
+		}
+	}
+
+	// Fill every small column up to 1 with a piece of a large one
+	for len(small) > 0 && len(large) > 0 {
+		less := small[len(small)-1]
+		small = small[:len(small)-1]
+		more := large[len(large)-1]
+		large = large[:len(large)-1]
+
+		table.prob[less] = scaled[less]
+		table.alias[less] = more
+
+		scaled[more] = scaled[more] + scaled[less] - 1
+		if scaled[more] < 1 {
+			small = append(small, more)
+		} else {
+			large = append(large, more)
+		}
+	}
+
+	// Whatever remains is 1 up to floating point error
+	for _, idx := range append(small, large...) {
+		table.prob[idx] = 1
+		table.alias[idx] = idx
+	}
+
+	return table
+}
@@ -0,0 +1,86 @@
+package algorithms
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestWeightedRandomSequenceIsPinnedBySeed(t *testing.T) {
+	expected := []int{0, 1, 0, 0, 2, 0, 0, 0, 0, 1, 0, 2, 0, 3, 0, 1}
+
+	backends := newTestPool(t, 5, 3, 1, 1)
+	alg, err := NewWeightedRandomAlg(backends, testSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sequence := pickIndexes(t, alg, backends, len(expected))
+	if !slices.Equal(sequence, expected) {
+		t.Fatalf("seed %d picked %v, expected %v", testSeed, sequence, expected)
+	}
+}
+
+func TestAliasTableMatchesWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights []int
+	}{
+		{name: "one backend", weights: []int{3}},
+		{name: "equal weights", weights: []int{2, 2, 2}},
+		{name: "mixed weights", weights: []int{5, 3, 1, 1}},
+		{name: "very heavy backend", weights: []int{100, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := buildAliasTable(newTestPool(t, tt.weights...).Backends())
+
+			// A backend owns prob of its own column and what is left of
+			// every column it is the alias of
+			n := float64(len(tt.weights))
+			share := make([]float64, len(tt.weights))
+			for idx, prob := range table.prob {
+				share[idx] += prob / n
+				share[table.alias[idx]] += (1 - prob) / n
+			}
+
+			totalWeight := 0
+			for _, weight := range tt.weights {
+				totalWeight += weight
+			}
+			for idx, weight := range tt.weights {
+				expected := float64(weight) / float64(totalWeight)
+				if math.Abs(share[idx]-expected) > 1e-9 {
+					t.Errorf("backend %d has share %.6f, expected %.6f", idx, share[idx], expected)
+				}
+			}
+		})
+	}
+}
+
+func TestWeightedRandomFrequencies(t *testing.T) {
+	const picks = 200_000
+	weights := []int{5, 3, 1, 1}
+
+	backends := newTestPool(t, weights...)
+	alg, err := NewWeightedRandomAlg(backends, testSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make([]int, len(weights))
+	for _, idx := range pickIndexes(t, alg, backends, picks) {
+		counts[idx]++
+	}
+
+	// Each count is within 5 standard deviations of its expected value
+	for idx, weight := range weights {
+		p := float64(weight) / 10
+		expected := p * picks
+		deviation := 5 * math.Sqrt(picks*p*(1-p))
+		if math.Abs(float64(counts[idx])-expected) > deviation {
+			t.Errorf("backend %d picked %d times, expected %.0f ± %.0f", idx, counts[idx], expected, deviation)
+		}
+	}
+}
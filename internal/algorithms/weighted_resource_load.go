@@ -0,0 +1,264 @@
+package algorithms
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/DucTran999/load-balancing-algo/pkg/loadreport"
+)
+
+// DefaultReportStaleAfter is how old a backend load report may get before the
+// weighted resource algorithm stops trusting it.
+const DefaultReportStaleAfter = 5 * time.Second
+
+// ResourceWeights sets how much each metric adds to a backend score. Every
+// metric is normalized to [0, 1] before weighting, so the weights only express
+// relative importance.
+type ResourceWeights struct {
+	CPU        float64
+	Memory     float64
+	InFlight   float64
+	QueueDepth float64
+	Latency    float64
+}
+
+// DefaultResourceWeights leans on CPU and memory and uses the load balancer's
+// own measurements to break ties.
+var DefaultResourceWeights = ResourceWeights{
+	CPU:        0.3,
+	Memory:     0.3,
+	InFlight:   0.2,
+	QueueDepth: 0.1,
+	Latency:    0.1,
+}
+
+func (w ResourceWeights) valid() bool {
+	for _, weight := range []float64{w.CPU, w.Memory, w.InFlight, w.QueueDepth, w.Latency} {
+		if weight < 0 || math.IsNaN(weight) {
+			return false
+		}
+	}
+
+	return w.CPU+w.Memory+w.InFlight+w.QueueDepth+w.Latency > 0
+}
+
+// resourceSample is the view of one backend a score is computed from.
+type resourceSample struct {
+	cpu        float64 // utilization in [0, 1]
+	memory     float64 // utilization in [0, 1]
+	queueDepth float64
+	inFlight   float64
+	latency    float64 // nanoseconds
+	fresh      bool
+}
+
+// weightedResourceLoadAlg combines the load the backends report in response
+// headers (CPU, memory, queue depth) with the in-flight count and latency
+// measured by the load balancer into one weighted score and picks the lowest.
+type weightedResourceLoadAlg struct {
+	pool       *pool.Pool
+	weights    ResourceWeights
+	staleAfter time.Duration
+	inFlight   inFlightTracker
+	latency    *latencyTracker
+	reports    loadReportStore
+	proxyCache sync.Map
+}
+
+func NewWeightedResourceLoadAlg(
+	backends *pool.Pool, weights ResourceWeights, staleAfter, decay time.Duration,
+) (*weightedResourceLoadAlg, error) {
+	if backends == nil || backends.Len() == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	if !weights.valid() {
+		return nil, errs.ErrInvalidResourceWeights
+	}
+
+	if staleAfter <= 0 {
+		return nil, errs.ErrInvalidStaleAfter
+	}
+
+	if decay <= 0 {
+		return nil, errs.ErrInvalidLatencyDecay
+	}
+
+	wrl := &weightedResourceLoadAlg{
+		pool:       backends,
+		weights:    weights,
+		staleAfter: staleAfter,
+		latency:    newLatencyTracker(decay, false),
+		proxyCache: sync.Map{},
+	}
+
+	return wrl, nil
+}
+
+func (lb *weightedResourceLoadAlg) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := lb.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	lb.Forward(w, r, target)
+}
+
+func (lb *weightedResourceLoadAlg) NextBackend(_ *http.Request, filter Filter) (*url.URL, func()) {
+	// The request is counted on its backend as part of the selection
+	return lb.inFlight.reserve(func() *url.URL { return lb.getNextBackend(filter) })
+}
+
+func (lb *weightedResourceLoadAlg) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	// Log the next URL to which the request will be forwarded
+	log.Printf("[INFO] load balancer forwarding request to: %v\n", target.String())
+
+	// Create a reverse proxy for the next backend
+	proxy := lb.getOrCreateProxy(target)
+
+	// Serve the request using the reverse proxy, the start time lets the
+	// proxy measure the round trip
+	proxy.ServeHTTP(w, withProxyStart(r))
+}
+
+func (lb *weightedResourceLoadAlg) getOrCreateProxy(target *url.URL) *httputil.ReverseProxy {
+	key := target.String()
+	if proxy, ok := lb.proxyCache.Load(key); ok {
+		return proxy.(*httputil.ReverseProxy)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	lb.latency.instrument(proxy, target)
+	lb.reports.instrument(proxy, target)
+	observeOutcome(proxy, target)
+	lb.proxyCache.Store(key, proxy)
+
+	return proxy
+}
+
+func (lb *weightedResourceLoadAlg) getNextBackend(filter Filter) *url.URL {
+	backends := lb.pool.Backends()
+	samples := make([]resourceSample, len(backends))
+	for idx, b := range backends {
+		samples[idx] = lb.sample(b)
+	}
+
+	// Unusable backends still take part in the normalization of the scores
+	scores := lb.scores(samples)
+	backendIdx := -1
+	for idx := range scores {
+		if !filter.Allows(backends[idx].GetUrl()) {
+			continue
+		}
+
+		if backendIdx == -1 || scores[idx] < scores[backendIdx] {
+			backendIdx = idx
+		}
+	}
+
+	if backendIdx == -1 {
+		return nil
+	}
+
+	log.Println("----------------------------------------------------")
+	log.Printf(
+		"[INFO] backend scores: %.3f, select: %d, score: %.3f\n",
+		scores, backendIdx, scores[backendIdx],
+	)
+
+	return backends[backendIdx].GetUrl()
+}
+
+func (lb *weightedResourceLoadAlg) sample(b *pool.Backend) resourceSample {
+	target := b.GetUrl()
+	latency, _ := lb.latency.average(target)
+	report, receivedAt, reported := lb.reports.latest(target)
+	queueDepth, _ := report.Named(loadreport.QueueDepthMetric)
+
+	return resourceSample{
+		cpu:        report.CPUUtilization,
+		memory:     report.MemUtilization,
+		queueDepth: queueDepth,
+		inFlight:   float64(lb.inFlight.count(target)),
+		latency:    float64(latency),
+		fresh:      reported && time.Since(receivedAt) <= lb.staleAfter,
+	}
+}
+
+// scores weights the normalized metrics of every sample. Reported metrics of a
+// stale backend are replaced by the average of the fresh ones, so an old report
+// neither hides an overloaded backend nor starves an idle one.
+func (lb *weightedResourceLoadAlg) scores(samples []resourceSample) []float64 {
+	var fallback resourceSample
+	freshCount := 0
+	maxQueue, maxInFlight, maxLatency := 0.0, 0.0, 0.0
+
+	for _, s := range samples {
+		maxInFlight = max(maxInFlight, s.inFlight)
+		maxLatency = max(maxLatency, s.latency)
+
+		if s.fresh {
+			fallback.cpu += s.cpu
+			fallback.memory += s.memory
+			fallback.queueDepth += s.queueDepth
+			maxQueue = max(maxQueue, s.queueDepth)
+			freshCount++
+		}
+	}
+
+	if freshCount > 0 {
+		fallback.cpu /= float64(freshCount)
+		fallback.memory /= float64(freshCount)
+		fallback.queueDepth /= float64(freshCount)
+	}
+
+	scores := make([]float64, len(samples))
+	for idx, s := range samples {
+		if !s.fresh {
+			s.cpu, s.memory, s.queueDepth = fallback.cpu, fallback.memory, fallback.queueDepth
+		}
+
+		scores[idx] = lb.weights.CPU*s.cpu +
+			lb.weights.Memory*s.memory +
+			lb.weights.QueueDepth*ratio(s.queueDepth, maxQueue) +
+			lb.weights.InFlight*ratio(s.inFlight, maxInFlight) +
+			lb.weights.Latency*ratio(s.latency, maxLatency)
+	}
+
+	return scores
+}
+
+// ratio normalizes value against the pool maximum, zero when the pool is idle.
+func ratio(value, maximum float64) float64 {
+	if maximum <= 0 {
+		return 0
+	}
+
+	return value / maximum
+}
+
+// ObserveLoadReport records a report polled out of band from target.
+func (lb *weightedResourceLoadAlg) ObserveLoadReport(target *url.URL, report loadreport.Report) {
+	lb.reports.record(target, report)
+}
+
+func (lb *weightedResourceLoadAlg) ExportState() *WarmState {
+	return &WarmState{inFlight: &lb.inFlight, latency: lb.latency, reports: &lb.reports}
+}
+
+// ImportState keeps counting the requests in flight on the previous algorithm
+// and starts from the latencies and load reports it gathered.
+func (lb *weightedResourceLoadAlg) ImportState(state *WarmState) {
+	lb.inFlight.adopt(state.inFlight)
+	lb.latency.seed(state.latency)
+	lb.reports.seed(state.reports)
+}
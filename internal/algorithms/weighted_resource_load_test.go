@@ -0,0 +1,26 @@
+package algorithms
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+)
+
+func TestWeightedResourceLoadUsesLatencyDecay(t *testing.T) {
+	backends := newTestPool(t, 1, 1)
+
+	alg, err := NewWeightedResourceLoadAlg(backends, DefaultResourceWeights, DefaultReportStaleAfter, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alg.latency.decay != time.Minute {
+		t.Fatalf("latency decay %v, expected 1m", alg.latency.decay)
+	}
+
+	_, err = NewWeightedResourceLoadAlg(backends, DefaultResourceWeights, DefaultReportStaleAfter, 0)
+	if !errors.Is(err, errs.ErrInvalidLatencyDecay) {
+		t.Fatalf("zero decay returned %v, expected %v", err, errs.ErrInvalidLatencyDecay)
+	}
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"log"
+
+	loadbalancer "github.com/DucTran999/load-balancing-algo/internal/load_blancer"
+	"github.com/DucTran999/load-balancing-algo/internal/tools"
+	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/rs/zerolog"
+)
+
+// RunAdminApp balances traffic while the admin API listens on localhost:9090,
+// e.g. curl localhost:9090/backends or
+// curl -X PUT -d '{"algorithm":"least-connection"}' localhost:9090/algorithm
+func RunAdminApp(logger zerolog.Logger) {
+	log.Println("[INFO] running admin app")
+
+	// Initialize the backend builder and configure number of backend servers
+	backendBuilder := backend.NewBackendBuilder(logger)
+	backendBuilder.SetNumberOfBackends(4)
+
+	// Build the backend servers
+	backends, err := backendBuilder.Build()
+	if err != nil {
+		logger.Fatal().Msgf("failed when build backends: %v", err)
+	}
+
+	// Create a new load balancer on localhost:8080 with the first three backends only,
+	// the last one is left for adding through the admin API
+	lb, err := loadbalancer.NewLoadBalancer("localhost", 8080, backends[:3], loadbalancer.RoundRobin)
+	if err != nil {
+		logger.Fatal().Msgf("failed to init loadbalancer: %v", err)
+	}
+
+	// Serve the admin API next to the load balancer
+	if err := lb.EnableAdmin(loadbalancer.DefaultAdminConfig()); err != nil {
+		logger.Fatal().Msgf("failed to enable admin api: %v", err)
+	}
+
+	// Start the load balancer asynchronously
+	if err := lb.Start(); err != nil {
+		logger.Fatal().Msgf("failed to start load balancer: %v", err)
+	}
+
+	// Initialize a request sender component and start sending requests one after the other
+	rs := tools.NewSequentialRequestSender(300)
+	go rs.SendNow()
+
+	// Wait for a graceful shutdown signal and stop the load balancer and backends cleanly
+	GracefulShutdown(logger, lb.Stop, backendBuilder.ShutdownAllBackends)
+}
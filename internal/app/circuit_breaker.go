@@ -0,0 +1,55 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	loadbalancer "github.com/DucTran999/load-balancing-algo/internal/load_blancer"
+	"github.com/DucTran999/load-balancing-algo/internal/tools"
+	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/rs/zerolog"
+)
+
+func RunCircuitBreakerApp(logger zerolog.Logger) {
+	log.Println("[INFO] running circuit breaker app")
+
+	// Initialize the backend builder and configure number of backend servers
+	backendBuilder := backend.NewBackendBuilder(logger)
+	backendBuilder.SetNumberOfBackends(5)
+
+	// Build the backend servers
+	backends, err := backendBuilder.Build()
+	if err != nil {
+		logger.Fatal().Msgf("failed when build backends: %v", err)
+	}
+
+	// Make the first backend fail most requests it receives
+	backends[0].SimulateFailures(0.8)
+
+	// Create a new load balancer on localhost:8080 using the backends and using round robin algorithm
+	lb, err := loadbalancer.NewLoadBalancer("localhost", 8080, backends, loadbalancer.RoundRobin)
+	if err != nil {
+		logger.Fatal().Msgf("failed to init loadbalancer: %v", err)
+	}
+
+	// Open the breaker after a few failures, short timeout to watch the trials
+	config := loadbalancer.DefaultCircuitBreakerConfig()
+	config.MinRequests = 2
+	config.OpenTimeout = 5 * time.Second
+	config.HalfOpenMaxRequests = 1
+	if err := lb.EnableCircuitBreaker(config); err != nil {
+		logger.Fatal().Msgf("failed to enable circuit breaker: %v", err)
+	}
+
+	// Start the load balancer asynchronously
+	if err := lb.Start(); err != nil {
+		logger.Fatal().Msgf("failed to start load balancer: %v", err)
+	}
+
+	// Initialize a request sender component and start sending requests asynchronously
+	rs := tools.NewRequestSender(40)
+	go rs.SendNow()
+
+	// Wait for a graceful shutdown signal and stop the load balancer and backends cleanly
+	GracefulShutdown(logger, lb.Stop, backendBuilder.ShutdownAllBackends)
+}
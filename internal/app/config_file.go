@@ -0,0 +1,34 @@
+package app
+
+import (
+	"log"
+
+	"github.com/DucTran999/load-balancing-algo/internal/config"
+	"github.com/rs/zerolog"
+)
+
+// RunConfigFileApp runs the load balancer described by the YAML or JSON file at
+// path, in front of backends running elsewhere.
+func RunConfigFileApp(logger zerolog.Logger, path string) {
+	log.Printf("[INFO] running load balancer from config %v\n", path)
+
+	// Read the config file, a typo in a field name is an error too
+	cfg, err := config.Load(path)
+	if err != nil {
+		logger.Fatal().Msgf("failed to load config: %v", err)
+	}
+
+	// Create the load balancer, the error lists every offending field
+	lb, err := cfg.Build()
+	if err != nil {
+		logger.Fatal().Msgf("failed to init loadbalancer: %v", err)
+	}
+
+	// Start the load balancer asynchronously
+	if err := lb.Start(); err != nil {
+		logger.Fatal().Msgf("failed to start load balancer: %v", err)
+	}
+
+	// Wait for a graceful shutdown signal and stop the load balancer cleanly
+	GracefulShutdown(logger, lb.Stop)
+}
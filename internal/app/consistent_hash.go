@@ -0,0 +1,43 @@
+package app
+
+import (
+	"log"
+
+	loadbalancer "github.com/DucTran999/load-balancing-algo/internal/load_blancer"
+	"github.com/DucTran999/load-balancing-algo/internal/tools"
+	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/rs/zerolog"
+)
+
+func RunConsistentHashApp(logger zerolog.Logger) {
+	log.Println("[INFO] running consistent hash algorithm app")
+
+	// Initialize the backend builder and configure number of backend servers
+	backendBuilder := backend.NewBackendBuilder(logger)
+	backendBuilder.SetNumberOfBackends(5)
+	backendBuilder.EnableRandomWeight() // Enable random weight for backends
+
+	// Build the backend servers
+	backends, err := backendBuilder.Build()
+	if err != nil {
+		logger.Fatal().Msgf("failed when build backends: %v", err)
+	}
+
+	// Create a new load balancer on localhost:8080 using the backends and consistent hash algorithm
+	lb, err := loadbalancer.NewLoadBalancer("localhost", 8080, backends, loadbalancer.ConsistentHash)
+	if err != nil {
+		logger.Fatal().Msgf("failed to init loadbalancer: %v", err)
+	}
+
+	// Start the load balancer asynchronously
+	if err := lb.Start(); err != nil {
+		logger.Fatal().Msgf("failed to start load balancer: %v", err)
+	}
+
+	// Initialize a request sender component and start sending requests asynchronously
+	rs := tools.NewRequestSender(20)
+	go rs.SendNow()
+
+	// Wait for a graceful shutdown signal and stop the load balancer and backends cleanly
+	GracefulShutdown(logger, lb.Stop, backendBuilder.ShutdownAllBackends)
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	loadbalancer "github.com/DucTran999/load-balancing-algo/internal/load_blancer"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/DucTran999/load-balancing-algo/internal/tools"
+	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/rs/zerolog"
+)
+
+func RunDynamicPoolApp(logger zerolog.Logger) {
+	log.Println("[INFO] running dynamic pool app")
+
+	// Initialize the backend builder and configure number of backend servers
+	backendBuilder := backend.NewBackendBuilder(logger)
+	backendBuilder.SetNumberOfBackends(4)
+
+	// Build the backend servers
+	backends, err := backendBuilder.Build()
+	if err != nil {
+		logger.Fatal().Msgf("failed when build backends: %v", err)
+	}
+
+	// Create a new load balancer on localhost:8080 with the first three backends only
+	lb, err := loadbalancer.NewLoadBalancer("localhost", 8080, backends[:3], loadbalancer.SmoothWeightedRoundRobin)
+	if err != nil {
+		logger.Fatal().Msgf("failed to init loadbalancer: %v", err)
+	}
+
+	// Start the load balancer asynchronously
+	if err := lb.Start(); err != nil {
+		logger.Fatal().Msgf("failed to start load balancer: %v", err)
+	}
+
+	// Initialize a request sender component and start sending requests one after the other
+	rs := tools.NewSequentialRequestSender(60)
+	go rs.SendNow()
+
+	// Change the pool while requests are flowing
+	go func() {
+		time.Sleep(5 * time.Second)
+		if err := lb.AddBackend(backends[3].GetUrl(), 1); err != nil {
+			logger.Warn().Err(err).Msg("failed to add backend")
+		}
+
+		time.Sleep(5 * time.Second)
+		if err := lb.SetBackendWeight(backends[3].GetUrl(), 3); err != nil {
+			logger.Warn().Err(err).Msg("failed to change backend weight")
+		}
+
+		time.Sleep(5 * time.Second)
+		if err := lb.SetBackendMode(backends[0].GetUrl(), pool.ModeDraining); err != nil {
+			logger.Warn().Err(err).Msg("failed to drain backend")
+		}
+
+		time.Sleep(5 * time.Second)
+		if err := lb.RemoveBackend(backends[0].GetUrl()); err != nil {
+			logger.Warn().Err(err).Msg("failed to remove backend")
+		}
+	}()
+
+	// Wait for a graceful shutdown signal and stop the load balancer and backends cleanly
+	GracefulShutdown(logger, lb.Stop, backendBuilder.ShutdownAllBackends)
+}
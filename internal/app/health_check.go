@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+
+	loadbalancer "github.com/DucTran999/load-balancing-algo/internal/load_blancer"
+	"github.com/DucTran999/load-balancing-algo/internal/tools"
+	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/rs/zerolog"
+)
+
+func RunHealthCheckApp(logger zerolog.Logger) {
+	log.Println("[INFO] running health check app")
+
+	// Initialize the backend builder and configure number of backend servers
+	backendBuilder := backend.NewBackendBuilder(logger)
+	backendBuilder.SetNumberOfBackends(5)
+
+	// Build the backend servers
+	backends, err := backendBuilder.Build()
+	if err != nil {
+		logger.Fatal().Msgf("failed when build backends: %v", err)
+	}
+
+	// Create a new load balancer on localhost:8080 using the backends and using round robin algorithm
+	lb, err := loadbalancer.NewLoadBalancer("localhost", 8080, backends, loadbalancer.RoundRobin)
+	if err != nil {
+		logger.Fatal().Msgf("failed to init loadbalancer: %v", err)
+	}
+
+	// Probe the backends so a stopped one is taken out of the rotation
+	if err := lb.EnableHealthCheck(loadbalancer.DefaultHealthCheckConfig()); err != nil {
+		logger.Fatal().Msgf("failed to enable health check: %v", err)
+	}
+
+	// Start the load balancer asynchronously
+	if err := lb.Start(); err != nil {
+		logger.Fatal().Msgf("failed to start load balancer: %v", err)
+	}
+
+	// Initialize a request sender component and start sending requests asynchronously
+	rs := tools.NewRequestSender(20)
+	go rs.SendNow()
+
+	// Simulate a crash of the first backend while requests are flowing
+	go func() {
+		time.Sleep(5 * time.Second)
+		log.Printf("[INFO] stopping backend %v\n", backends[0].GetUrl())
+		if err := backends[0].Stop(context.Background()); err != nil {
+			logger.Warn().Err(err).Msg("failed to stop backend")
+		}
+	}()
+
+	// Wait for a graceful shutdown signal and stop the load balancer and backends cleanly
+	GracefulShutdown(logger, lb.Stop, backendBuilder.ShutdownAllBackends)
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	loadbalancer "github.com/DucTran999/load-balancing-algo/internal/load_blancer"
+	"github.com/DucTran999/load-balancing-algo/internal/tools"
+	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/rs/zerolog"
+)
+
+func RunHedgingApp(logger zerolog.Logger) {
+	log.Println("[INFO] running hedging app")
+
+	// Initialize the backend builder and configure number of backend servers
+	backendBuilder := backend.NewBackendBuilder(logger)
+	backendBuilder.SetNumberOfBackends(5)
+
+	// Build the backend servers
+	backends, err := backendBuilder.Build()
+	if err != nil {
+		logger.Fatal().Msgf("failed when build backends: %v", err)
+	}
+
+	// Create a new load balancer on localhost:8080 using the backends and using round robin algorithm
+	lb, err := loadbalancer.NewLoadBalancer("localhost", 8080, backends, loadbalancer.RoundRobin)
+	if err != nil {
+		logger.Fatal().Msgf("failed to init loadbalancer: %v", err)
+	}
+
+	// Send a duplicate to another backend when the first one is slow to answer
+	config := loadbalancer.DefaultHedgingConfig()
+	config.Delay = 200 * time.Millisecond
+	config.Percentile = 0
+	config.BudgetRatio = 0.5
+	if err := lb.EnableHedging(config); err != nil {
+		logger.Fatal().Msgf("failed to enable hedging: %v", err)
+	}
+
+	// Make the first backend take a second to answer every request
+	backends[0].SimulateDelay(time.Second)
+
+	// Start the load balancer asynchronously
+	if err := lb.Start(); err != nil {
+		logger.Fatal().Msgf("failed to start load balancer: %v", err)
+	}
+
+	// Initialize a request sender component and start sending requests asynchronously
+	rs := tools.NewRequestSender(40)
+	go rs.SendNow()
+
+	// Wait for a graceful shutdown signal and stop the load balancer and backends cleanly
+	GracefulShutdown(logger, lb.Stop, backendBuilder.ShutdownAllBackends)
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	loadbalancer "github.com/DucTran999/load-balancing-algo/internal/load_blancer"
+	"github.com/DucTran999/load-balancing-algo/internal/tools"
+	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/rs/zerolog"
+)
+
+func RunHotSwapApp(logger zerolog.Logger) {
+	log.Println("[INFO] running hot swap app")
+
+	// Initialize the backend builder and configure number of backend servers
+	backendBuilder := backend.NewBackendBuilder(logger)
+	backendBuilder.SetNumberOfBackends(3)
+
+	// Build the backend servers
+	backends, err := backendBuilder.Build()
+	if err != nil {
+		logger.Fatal().Msgf("failed when build backends: %v", err)
+	}
+
+	// Create a new load balancer on localhost:8080 using the backends and using peak EWMA algorithm
+	lb, err := loadbalancer.NewLoadBalancer("localhost", 8080, backends, loadbalancer.PeakEWMA)
+	if err != nil {
+		logger.Fatal().Msgf("failed to init loadbalancer: %v", err)
+	}
+
+	// Make the first backend slow so the latency aware algorithms avoid it
+	backends[0].SimulateDelay(500 * time.Millisecond)
+
+	// Start the load balancer asynchronously
+	if err := lb.Start(); err != nil {
+		logger.Fatal().Msgf("failed to start load balancer: %v", err)
+	}
+
+	// Initialize a request sender component and start sending requests asynchronously
+	rs := tools.NewRequestSender(100)
+	go rs.SendNow()
+
+	// A/B the algorithms while requests are flowing, the warm started lowest
+	// latency keeps avoiding the slow backend from its first request
+	go func() {
+		for _, alg := range []loadbalancer.Algorithm{
+			loadbalancer.LowestLatency, loadbalancer.LeastConnection, loadbalancer.PeakEWMA,
+		} {
+			time.Sleep(3 * time.Second)
+			if _, err := lb.SwitchAlgorithm(alg, true); err != nil {
+				logger.Warn().Err(err).Msg("failed to switch algorithm")
+			}
+		}
+	}()
+
+	// Wait for a graceful shutdown signal and stop the load balancer and backends cleanly
+	GracefulShutdown(logger, lb.Stop, backendBuilder.ShutdownAllBackends)
+}
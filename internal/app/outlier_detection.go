@@ -0,0 +1,54 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	loadbalancer "github.com/DucTran999/load-balancing-algo/internal/load_blancer"
+	"github.com/DucTran999/load-balancing-algo/internal/tools"
+	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/rs/zerolog"
+)
+
+func RunOutlierDetectionApp(logger zerolog.Logger) {
+	log.Println("[INFO] running outlier detection app")
+
+	// Initialize the backend builder and configure number of backend servers
+	backendBuilder := backend.NewBackendBuilder(logger)
+	backendBuilder.SetNumberOfBackends(5)
+
+	// Build the backend servers
+	backends, err := backendBuilder.Build()
+	if err != nil {
+		logger.Fatal().Msgf("failed when build backends: %v", err)
+	}
+
+	// Make the first backend fail every request it receives
+	backends[0].SimulateFailures(1)
+
+	// Create a new load balancer on localhost:8080 using the backends and using round robin algorithm
+	lb, err := loadbalancer.NewLoadBalancer("localhost", 8080, backends, loadbalancer.RoundRobin)
+	if err != nil {
+		logger.Fatal().Msgf("failed to init loadbalancer: %v", err)
+	}
+
+	// Eject the failing backend after a few errors, short periods to watch it come back
+	config := loadbalancer.DefaultOutlierDetectionConfig()
+	config.Consecutive5xx = 2
+	config.BaseEjectionTime = 5 * time.Second
+	if err := lb.EnableOutlierDetection(config); err != nil {
+		logger.Fatal().Msgf("failed to enable outlier detection: %v", err)
+	}
+
+	// Start the load balancer asynchronously
+	if err := lb.Start(); err != nil {
+		logger.Fatal().Msgf("failed to start load balancer: %v", err)
+	}
+
+	// Initialize a request sender component and start sending requests asynchronously
+	rs := tools.NewRequestSender(40)
+	go rs.SendNow()
+
+	// Wait for a graceful shutdown signal and stop the load balancer and backends cleanly
+	GracefulShutdown(logger, lb.Stop, backendBuilder.ShutdownAllBackends)
+}
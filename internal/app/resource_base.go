@@ -37,6 +37,6 @@ func RunResourceBaseApp(logger zerolog.Logger) {
 	rs := tools.NewRequestSender(20)
 	go rs.SendNow()
 
-	// Wait for a graceful shutdown signal and stop the first backend cleanly
-	GracefulShutdown(logger, backendBuilder.ShutdownAllBackends)
+	// Wait for a graceful shutdown signal and stop the load balancer and backends cleanly
+	GracefulShutdown(logger, lb.Stop, backendBuilder.ShutdownAllBackends)
 }
@@ -0,0 +1,202 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+)
+
+// DefaultTrustedProxies trusts proxies on the loopback interface only, which is
+// where the demo apps send their requests from.
+var DefaultTrustedProxies = []string{"127.0.0.0/8", "::1/128"}
+
+// The forwarding headers a resolver can read the client address from.
+const (
+	HeaderForwarded     = "Forwarded"
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderXRealIP       = "X-Real-IP"
+)
+
+// Resolver finds the address of the client that originated a request. Proxy
+// headers are only believed when they were added by a trusted proxy, so a
+// client cannot pick its own address by sending them.
+type Resolver struct {
+	header  string
+	trusted []netip.Prefix
+}
+
+// NewResolver builds a resolver trusting the given CIDR ranges, a bare IP
+// address trusts that single host. Only header is read, the one the trusted
+// proxies set: a client could send any other one through them unchanged. An
+// empty header reads X-Forwarded-For.
+func NewResolver(header string, trustedProxies []string) (*Resolver, error) {
+	res := &Resolver{}
+
+	switch {
+	case header == "" || strings.EqualFold(header, HeaderXForwardedFor):
+		res.header = HeaderXForwardedFor
+	case strings.EqualFold(header, HeaderForwarded):
+		res.header = HeaderForwarded
+	case strings.EqualFold(header, HeaderXRealIP):
+		res.header = HeaderXRealIP
+	default:
+		return nil, fmt.Errorf("%w: %q", errs.ErrInvalidProxyHeader, header)
+	}
+
+	for _, proxy := range trustedProxies {
+		prefix, err := parsePrefix(strings.TrimSpace(proxy))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", errs.ErrInvalidTrustedProxy, proxy)
+		}
+		res.trusted = append(res.trusted, prefix)
+	}
+
+	return res, nil
+}
+
+// ClientIP returns the client address of r. When the peer is a trusted proxy
+// the forwarding header of the resolver is consulted; forwarding chains are
+// read right to left, skipping trusted proxies, and the first untrusted hop is
+// the client.
+func (res *Resolver) ClientIP(r *http.Request) string {
+	remote, ok := RemoteAddr(r)
+	if !ok {
+		return r.RemoteAddr
+	}
+
+	if !res.isTrusted(remote) {
+		return remote.String()
+	}
+
+	var hops []string
+	switch res.header {
+	case HeaderForwarded:
+		hops = forwardedFor(r.Header.Values(HeaderForwarded))
+	case HeaderXForwardedFor:
+		hops = splitList(r.Header.Values(HeaderXForwardedFor))
+	case HeaderXRealIP:
+		if realIP, ok := parseAddr(r.Header.Get(HeaderXRealIP)); ok {
+			return realIP.String()
+		}
+	}
+
+	if len(hops) > 0 {
+		return res.walkChain(hops, remote).String()
+	}
+
+	return remote.String()
+}
+
+// walkChain reads a forwarding chain right to left. A hop that is not an
+// address (obfuscated or "unknown") cannot be trusted nor reported, so the
+// proxy that added it is returned instead.
+func (res *Resolver) walkChain(hops []string, remote netip.Addr) netip.Addr {
+	nearest := remote
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(hops[i])
+		if !ok {
+			return nearest
+		}
+
+		if !res.isTrusted(addr) {
+			return addr
+		}
+		nearest = addr
+	}
+
+	// Every hop is trusted, the leftmost one is the best guess
+	return nearest
+}
+
+func (res *Resolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range res.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RemoteAddr returns the address of the direct peer of r, which cannot be
+// spoofed by request headers.
+func RemoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return parseAddr(host)
+}
+
+// forwardedFor extracts the for= parameters of RFC 7239 Forwarded headers, in
+// the order the proxies appended them.
+func forwardedFor(values []string) []string {
+	var hops []string
+
+	for _, element := range splitList(values) {
+		for _, pair := range strings.Split(element, ";") {
+			key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if found && strings.EqualFold(key, "for") {
+				hops = append(hops, strings.Trim(value, `"`))
+			}
+		}
+	}
+
+	return hops
+}
+
+// splitList flattens comma separated header values, a header may be sent on
+// several lines.
+func splitList(values []string) []string {
+	var items []string
+
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items
+}
+
+// parseAddr accepts an address with or without port, IPv6 optionally in
+// brackets as used by the Forwarded header.
+func parseAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}, false
+	}
+
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
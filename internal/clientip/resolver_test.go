@@ -0,0 +1,203 @@
+package clientip
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+)
+
+var testTrustedProxies = []string{"10.0.0.0/8", "fd00::/8", "192.0.2.1"}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:   "untrusted peer without headers",
+			header: HeaderXForwardedFor,
+			remote: "198.51.100.7:5000",
+			want:   "198.51.100.7",
+		},
+		{
+			name:    "untrusted peer headers are ignored",
+			header:  HeaderXForwardedFor,
+			remote:  "198.51.100.7:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.9"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "trusted peer without the header",
+			header:  HeaderXForwardedFor,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "single hop",
+			header:  HeaderXForwardedFor,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.9"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "multi hop skips trusted proxies",
+			header:  HeaderXForwardedFor,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.9, 10.0.0.3", "192.0.2.1"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "spoofed leading entries are not believed",
+			header:  HeaderXForwardedFor,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4, 5.6.7.8, 203.0.113.9"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "unknown hop stops at the proxy that added it",
+			header:  HeaderXForwardedFor,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.9, unknown, 10.0.0.3"}},
+			want:    "10.0.0.3",
+		},
+		{
+			name:    "every hop trusted returns the leftmost",
+			header:  HeaderXForwardedFor,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"10.0.0.5, 10.0.0.3"}},
+			want:    "10.0.0.5",
+		},
+		{
+			name:   "forwarded is ignored when proxies set x-forwarded-for",
+			header: HeaderXForwardedFor,
+			remote: "10.0.0.1:5000",
+			headers: map[string][]string{
+				"Forwarded":       {"for=1.2.3.4"},
+				"X-Forwarded-For": {"203.0.113.9"},
+			},
+			want: "203.0.113.9",
+		},
+		{
+			name:    "spoofed forwarded alone is ignored",
+			header:  HeaderXForwardedFor,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {"for=1.2.3.4"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "forwarded quoted ipv6 with port",
+			header:  HeaderForwarded,
+			remote:  "[fd00::1]:5000",
+			headers: map[string][]string{"Forwarded": {`for="[2001:db8::17]:4711";proto=https`}},
+			want:    "2001:db8::17",
+		},
+		{
+			name:    "forwarded ipv4 with port and other parameters",
+			header:  HeaderForwarded,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {`proto=http;For="203.0.113.9:8080";by=10.0.0.1`}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:   "forwarded multi hop over several lines",
+			header: HeaderForwarded,
+			remote: "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {
+				"for=1.2.3.4, for=203.0.113.9",
+				"for=10.0.0.3",
+			}},
+			want: "203.0.113.9",
+		},
+		{
+			name:    "forwarded obfuscated hop stops at the proxy that added it",
+			header:  HeaderForwarded,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {`for=203.0.113.9, for="_hidden"`}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "x-forwarded-for is ignored when proxies set forwarded",
+			header:  HeaderForwarded,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "x-real-ip",
+			header:  HeaderXRealIP,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"X-Real-Ip": {"203.0.113.9"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:    "x-real-ip ipv6 in brackets",
+			header:  HeaderXRealIP,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"X-Real-Ip": {"[2001:db8::17]"}},
+			want:    "2001:db8::17",
+		},
+		{
+			name:    "x-real-ip that is not an address",
+			header:  HeaderXRealIP,
+			remote:  "10.0.0.1:5000",
+			headers: map[string][]string{"X-Real-Ip": {"nope"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "x-real-ip from an untrusted peer",
+			header:  HeaderXRealIP,
+			remote:  "198.51.100.7:5000",
+			headers: map[string][]string{"X-Real-Ip": {"203.0.113.9"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "ipv4 mapped peer is unmapped",
+			header:  HeaderXForwardedFor,
+			remote:  "[::ffff:10.0.0.1]:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.9"}},
+			want:    "203.0.113.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := NewResolver(tt.header, testTrustedProxies)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for name, values := range tt.headers {
+				for _, value := range values {
+					r.Header.Add(name, value)
+				}
+			}
+
+			if got := res.ClientIP(r); got != tt.want {
+				t.Fatalf("client IP %q, expected %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewResolverRejectsInvalidConfig(t *testing.T) {
+	if _, err := NewResolver("X-Client-IP", nil); !errors.Is(err, errs.ErrInvalidProxyHeader) {
+		t.Errorf("unknown header returned %v, expected %v", err, errs.ErrInvalidProxyHeader)
+	}
+
+	if _, err := NewResolver("", []string{"10.0.0.0/33"}); !errors.Is(err, errs.ErrInvalidTrustedProxy) {
+		t.Errorf("invalid range returned %v, expected %v", err, errs.ErrInvalidTrustedProxy)
+	}
+
+	res, err := NewResolver("x-forwarded-for", nil)
+	if err != nil || res.header != HeaderXForwardedFor {
+		t.Errorf("header names are case insensitive, got %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/algorithms"
+	"github.com/DucTran999/load-balancing-algo/internal/clientip"
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	loadbalancer "github.com/DucTran999/load-balancing-algo/internal/load_blancer"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+const (
+	hashKeySourceIP = "source-ip"
+	hashKeyHeader   = "header"
+	hashKeyCookie   = "cookie"
+	hashKeyQuery    = "query"
+	hashKeyPath     = "path"
+
+	hashKeyFallback = "fallback"
+	hashKeyCombine  = "combine"
+)
+
+// FieldError tells which field of the config is wrong, e.g.
+// backends[1].weight.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldErrors collects every mistake of a config, so they are reported at once.
+type fieldErrors []error
+
+func (fe *fieldErrors) add(field string, err error) {
+	if err != nil {
+		*fe = append(*fe, &FieldError{Field: field, Err: err})
+	}
+}
+
+func (fe *fieldErrors) duration(field string, value Duration) time.Duration {
+	d, err := time.ParseDuration(string(value))
+	if err != nil {
+		fe.add(field, fmt.Errorf("invalid duration %q", value))
+	}
+
+	return d
+}
+
+func (fe fieldErrors) err() error {
+	if len(fe) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %w", errs.ErrInvalidConfig, errors.Join(fe...))
+}
+
+// Build creates the load balancer the config describes without starting it.
+// A wrong field is reported by its path in the file.
+func (c *Config) Build() (loadbalancer.LoadBalancer, error) {
+	var fe fieldErrors
+
+	listener := loadbalancer.ListenerConfig{
+		Host:           c.Listener.Host,
+		Port:           c.Listener.Port,
+		ReadTimeout:    fe.duration("listener.read_timeout", c.Listener.ReadTimeout),
+		WriteTimeout:   fe.duration("listener.write_timeout", c.Listener.WriteTimeout),
+		IdleTimeout:    fe.duration("listener.idle_timeout", c.Listener.IdleTimeout),
+		TrustedProxies: c.Listener.TrustedProxies,
+		ProxyHeader:    c.Listener.ProxyHeader,
+	}
+
+	// The source IP hash key resolves client addresses like the load balancer
+	resolver, err := clientip.NewResolver(listener.ProxyHeader, listener.TrustedProxies)
+	if errors.Is(err, errs.ErrInvalidProxyHeader) {
+		fe.add("listener.proxy_header", err)
+	} else {
+		fe.add("listener.trusted_proxies", err)
+	}
+
+	backends := c.backendPool(&fe)
+
+	var alg loadbalancer.Algorithm
+	if err := alg.UnmarshalText([]byte(c.Algorithm.Name)); err != nil {
+		fe.add("algorithm.name", err)
+	}
+	params := c.algorithmParams(&fe, resolver)
+
+	health := c.healthCheck(&fe)
+	retry := c.retry(&fe)
+
+	// Nothing is built on top of fields that could not be read
+	if err := fe.err(); err != nil {
+		return nil, err
+	}
+
+	lb, err := loadbalancer.NewLoadBalancerWithConfig(listener, backends, alg)
+	if errors.Is(err, errs.ErrInvalidListener) {
+		fe.add("listener", err)
+		return nil, fe.err()
+	}
+	if err != nil {
+		fe.add(algorithmField(err), err)
+		return nil, fe.err()
+	}
+
+	if err := lb.SetAlgorithmParams(params); err != nil {
+		fe.add(algorithmField(err), err)
+	}
+
+	if c.HealthCheck.Enabled {
+		fe.add("health_check", lb.EnableHealthCheck(health))
+	}
+
+	if c.Retry.Enabled {
+		fe.add("retry", lb.EnableRetry(retry))
+	}
+
+	if c.Admin.Enabled {
+		fe.add("admin", lb.EnableAdmin(loadbalancer.AdminConfig{
+			Host:  c.Admin.Host,
+			Port:  c.Admin.Port,
+			Token: c.Admin.Token,
+		}))
+	}
+
+	if err := fe.err(); err != nil {
+		return nil, err
+	}
+
+	return lb, nil
+}
+
+// algorithmField names the algorithm parameter err is about.
+func algorithmField(err error) string {
+	switch {
+	case errors.Is(err, errs.ErrInvalidVirtualNodes):
+		return "algorithm.virtual_nodes"
+	case errors.Is(err, errs.ErrInvalidTableSize):
+		return "algorithm.maglev_table_size"
+	case errors.Is(err, errs.ErrInvalidLoadFactor):
+		return "algorithm.load_factor"
+	case errors.Is(err, errs.ErrInvalidLatencyDecay):
+		return "algorithm.latency_decay"
+	case errors.Is(err, errs.ErrInvalidResourceWeights):
+		return "algorithm.resource_weights"
+	case errors.Is(err, errs.ErrInvalidStaleAfter):
+		return "algorithm.report_stale_after"
+	default:
+		return "algorithm"
+	}
+}
+
+func (c *Config) backendPool(fe *fieldErrors) *pool.Pool {
+	if len(c.Backends) == 0 {
+		fe.add("backends", errs.ErrNoTargetServersFound)
+	}
+
+	backends := pool.New()
+	for idx, b := range c.Backends {
+		field := fmt.Sprintf("backends[%d]", idx)
+
+		target, err := url.Parse(b.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			fe.add(field+".url", fmt.Errorf("%w: %q", errs.ErrInvalidBackendUrl, b.URL))
+			continue
+		}
+
+		weight := 1
+		if b.Weight != nil {
+			weight = *b.Weight
+		}
+
+		err = backends.Add(target, weight)
+		if errors.Is(err, errs.ErrInvalidWeight) {
+			fe.add(field+".weight", err)
+		} else {
+			fe.add(field+".url", err)
+		}
+	}
+
+	return backends
+}
+
+func (c *Config) algorithmParams(fe *fieldErrors, resolver *clientip.Resolver) loadbalancer.AlgorithmParams {
+	alg := c.Algorithm
+	params := loadbalancer.AlgorithmParams{
+		VirtualNodes:     alg.VirtualNodes,
+		MaglevTableSize:  alg.MaglevTableSize,
+		LoadFactor:       alg.LoadFactor,
+		LatencyDecay:     fe.duration("algorithm.latency_decay", alg.LatencyDecay),
+		ReportStaleAfter: fe.duration("algorithm.report_stale_after", alg.ReportStaleAfter),
+		ResourceWeights: algorithms.ResourceWeights{
+			CPU:        alg.ResourceWeights.CPU,
+			Memory:     alg.ResourceWeights.Memory,
+			InFlight:   alg.ResourceWeights.InFlight,
+			QueueDepth: alg.ResourceWeights.QueueDepth,
+			Latency:    alg.ResourceWeights.Latency,
+		},
+		HashKey: hashKey(fe, alg.HashKey, resolver),
+		Seed:    alg.Seed,
+	}
+
+	return params
+}
+
+// hashKey builds the extractor of key, nil when it is the client IP alone which
+// is what the load balancer routes on by default.
+func hashKey(fe *fieldErrors, key HashKey, resolver *clientip.Resolver) algorithms.KeyExtractor {
+	if len(key.Sources) == 0 {
+		fe.add("algorithm.hash_key.sources", errors.New("at least one source is required"))
+		return nil
+	}
+
+	named := []string{hashKeyHeader, hashKeyCookie, hashKeyQuery}
+	extractors := make([]algorithms.KeyExtractor, 0, len(key.Sources))
+	for idx, source := range key.Sources {
+		field := fmt.Sprintf("algorithm.hash_key.sources[%d]", idx)
+		if slices.Contains(named, source.Source) && source.Name == "" {
+			fe.add(field+".name", fmt.Errorf("required for a %s key", source.Source))
+		}
+
+		switch source.Source {
+		case hashKeySourceIP:
+			extractors = append(extractors, algorithms.SourceIPKey(resolver))
+		case hashKeyHeader:
+			extractors = append(extractors, algorithms.HeaderKey(source.Name))
+		case hashKeyCookie:
+			extractors = append(extractors, algorithms.CookieKey(source.Name))
+		case hashKeyQuery:
+			extractors = append(extractors, algorithms.QueryKey(source.Name))
+		case hashKeyPath:
+			extractors = append(extractors, algorithms.PathKey())
+		default:
+			fe.add(field+".source", fmt.Errorf("unknown source %q", source.Source))
+		}
+	}
+
+	switch key.Mode {
+	case hashKeyFallback:
+		if len(key.Sources) == 1 && key.Sources[0].Source == hashKeySourceIP {
+			return nil
+		}
+		return algorithms.FallbackKey(extractors...)
+	case hashKeyCombine:
+		return algorithms.CombinedKey(extractors...)
+	default:
+		fe.add("algorithm.hash_key.mode", fmt.Errorf("unknown mode %q, expected %s or %s", key.Mode, hashKeyFallback, hashKeyCombine))
+		return nil
+	}
+}
+
+func (c *Config) healthCheck(fe *fieldErrors) loadbalancer.HealthCheckConfig {
+	hc := c.HealthCheck
+	if !hc.Enabled {
+		return loadbalancer.HealthCheckConfig{}
+	}
+
+	return loadbalancer.HealthCheckConfig{
+		Path:               hc.Path,
+		Interval:           fe.duration("health_check.interval", hc.Interval),
+		Timeout:            fe.duration("health_check.timeout", hc.Timeout),
+		ExpectedStatus:     hc.ExpectedStatus,
+		HealthyThreshold:   hc.HealthyThreshold,
+		UnhealthyThreshold: hc.UnhealthyThreshold,
+	}
+}
+
+func (c *Config) retry(fe *fieldErrors) loadbalancer.RetryConfig {
+	rt := c.Retry
+	if !rt.Enabled {
+		return loadbalancer.RetryConfig{}
+	}
+
+	return loadbalancer.RetryConfig{
+		MaxAttempts:   rt.MaxAttempts,
+		RetryOn:       rt.RetryOn,
+		PerTryTimeout: fe.duration("retry.per_try_timeout", rt.PerTryTimeout),
+		BaseBackoff:   fe.duration("retry.base_backoff", rt.BaseBackoff),
+		MaxBackoff:    fe.duration("retry.max_backoff", rt.MaxBackoff),
+		BudgetRatio:   rt.BudgetRatio,
+		BudgetBurst:   rt.BudgetBurst,
+		MaxBodyBytes:  rt.MaxBodyBytes,
+	}
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	loadbalancer "github.com/DucTran999/load-balancing-algo/internal/load_blancer"
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes a load balancer, as written in a YAML or JSON file. Every
+// field left out of the file keeps its default, optional features stay off
+// unless enabled.
+type Config struct {
+	Listener    Listener    `yaml:"listener" json:"listener"`
+	Backends    []Backend   `yaml:"backends" json:"backends"`
+	Algorithm   Algorithm   `yaml:"algorithm" json:"algorithm"`
+	HealthCheck HealthCheck `yaml:"health_check" json:"health_check"`
+	Retry       Retry       `yaml:"retry" json:"retry"`
+	Admin       Admin       `yaml:"admin" json:"admin"`
+}
+
+// Duration is written the Go way, e.g. "1.5s" or "300ms".
+type Duration string
+
+type Listener struct {
+	Host         string   `yaml:"host" json:"host"`
+	Port         int      `yaml:"port" json:"port"`
+	ReadTimeout  Duration `yaml:"read_timeout" json:"read_timeout"`
+	WriteTimeout Duration `yaml:"write_timeout" json:"write_timeout"`
+	IdleTimeout  Duration `yaml:"idle_timeout" json:"idle_timeout"`
+	// TrustedProxies are the CIDR ranges whose forwarding headers are believed
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"`
+	// ProxyHeader is the one forwarding header they set: X-Forwarded-For,
+	// Forwarded or X-Real-IP
+	ProxyHeader string `yaml:"proxy_header" json:"proxy_header"`
+}
+
+type Backend struct {
+	URL string `yaml:"url" json:"url"`
+	// Weight defaults to 1
+	Weight *int `yaml:"weight" json:"weight"`
+}
+
+type Algorithm struct {
+	// Name is the kebab-case name of the algorithm, e.g. least-connection
+	Name             string          `yaml:"name" json:"name"`
+	VirtualNodes     int             `yaml:"virtual_nodes" json:"virtual_nodes"`
+	MaglevTableSize  int             `yaml:"maglev_table_size" json:"maglev_table_size"`
+	LoadFactor       float64         `yaml:"load_factor" json:"load_factor"`
+	LatencyDecay     Duration        `yaml:"latency_decay" json:"latency_decay"`
+	ReportStaleAfter Duration        `yaml:"report_stale_after" json:"report_stale_after"`
+	ResourceWeights  ResourceWeights `yaml:"resource_weights" json:"resource_weights"`
+	HashKey          HashKey         `yaml:"hash_key" json:"hash_key"`
+	// Seed makes the random algorithms reproducible, 0 seeds them at random
+	Seed uint64 `yaml:"seed" json:"seed"`
+}
+
+type ResourceWeights struct {
+	CPU        float64 `yaml:"cpu" json:"cpu"`
+	Memory     float64 `yaml:"memory" json:"memory"`
+	InFlight   float64 `yaml:"in_flight" json:"in_flight"`
+	QueueDepth float64 `yaml:"queue_depth" json:"queue_depth"`
+	Latency    float64 `yaml:"latency" json:"latency"`
+}
+
+// HashKey is what the hash based algorithms route on. In fallback mode the
+// first of the Sources a request carries is the key, in combine mode the key
+// joins them all and misses when one is missing. A request without a key routes
+// on its client IP.
+type HashKey struct {
+	Mode    string          `yaml:"mode" json:"mode"`
+	Sources []HashKeySource `yaml:"sources" json:"sources"`
+}
+
+// HashKeySource is the source-ip, or the header, cookie or query parameter
+// called Name, or the path.
+type HashKeySource struct {
+	Source string `yaml:"source" json:"source"`
+	Name   string `yaml:"name" json:"name"`
+}
+
+type HealthCheck struct {
+	Enabled            bool     `yaml:"enabled" json:"enabled"`
+	Path               string   `yaml:"path" json:"path"`
+	Interval           Duration `yaml:"interval" json:"interval"`
+	Timeout            Duration `yaml:"timeout" json:"timeout"`
+	ExpectedStatus     int      `yaml:"expected_status" json:"expected_status"`
+	HealthyThreshold   int      `yaml:"healthy_threshold" json:"healthy_threshold"`
+	UnhealthyThreshold int      `yaml:"unhealthy_threshold" json:"unhealthy_threshold"`
+}
+
+type Retry struct {
+	Enabled       bool     `yaml:"enabled" json:"enabled"`
+	MaxAttempts   int      `yaml:"max_attempts" json:"max_attempts"`
+	RetryOn       []int    `yaml:"retry_on" json:"retry_on"`
+	PerTryTimeout Duration `yaml:"per_try_timeout" json:"per_try_timeout"`
+	BaseBackoff   Duration `yaml:"base_backoff" json:"base_backoff"`
+	MaxBackoff    Duration `yaml:"max_backoff" json:"max_backoff"`
+	BudgetRatio   float64  `yaml:"budget_ratio" json:"budget_ratio"`
+	BudgetBurst   int      `yaml:"budget_burst" json:"budget_burst"`
+	MaxBodyBytes  int64    `yaml:"max_body_bytes" json:"max_body_bytes"`
+}
+
+type Admin struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Host    string `yaml:"host" json:"host"`
+	Port    int    `yaml:"port" json:"port"`
+	Token   string `yaml:"token" json:"token"`
+}
+
+// Default is the config a file is laid over, it has no backends.
+func Default() *Config {
+	listener := loadbalancer.DefaultListenerConfig("localhost", 8080)
+	params := loadbalancer.DefaultAlgorithmParams()
+	health := loadbalancer.DefaultHealthCheckConfig()
+	retry := loadbalancer.DefaultRetryConfig()
+	admin := loadbalancer.DefaultAdminConfig()
+
+	return &Config{
+		Listener: Listener{
+			Host:           listener.Host,
+			Port:           listener.Port,
+			ReadTimeout:    Duration(listener.ReadTimeout.String()),
+			WriteTimeout:   Duration(listener.WriteTimeout.String()),
+			IdleTimeout:    Duration(listener.IdleTimeout.String()),
+			TrustedProxies: listener.TrustedProxies,
+			ProxyHeader:    listener.ProxyHeader,
+		},
+		Algorithm: Algorithm{
+			Name:             "round-robin",
+			VirtualNodes:     params.VirtualNodes,
+			MaglevTableSize:  params.MaglevTableSize,
+			LoadFactor:       params.LoadFactor,
+			LatencyDecay:     Duration(params.LatencyDecay.String()),
+			ReportStaleAfter: Duration(params.ReportStaleAfter.String()),
+			ResourceWeights: ResourceWeights{
+				CPU:        params.ResourceWeights.CPU,
+				Memory:     params.ResourceWeights.Memory,
+				InFlight:   params.ResourceWeights.InFlight,
+				QueueDepth: params.ResourceWeights.QueueDepth,
+				Latency:    params.ResourceWeights.Latency,
+			},
+			HashKey: HashKey{
+				Mode:    hashKeyFallback,
+				Sources: []HashKeySource{{Source: hashKeySourceIP}},
+			},
+		},
+		HealthCheck: HealthCheck{
+			Path:               health.Path,
+			Interval:           Duration(health.Interval.String()),
+			Timeout:            Duration(health.Timeout.String()),
+			ExpectedStatus:     health.ExpectedStatus,
+			HealthyThreshold:   health.HealthyThreshold,
+			UnhealthyThreshold: health.UnhealthyThreshold,
+		},
+		Retry: Retry{
+			MaxAttempts:   retry.MaxAttempts,
+			RetryOn:       retry.RetryOn,
+			PerTryTimeout: Duration(retry.PerTryTimeout.String()),
+			BaseBackoff:   Duration(retry.BaseBackoff.String()),
+			MaxBackoff:    Duration(retry.MaxBackoff.String()),
+			BudgetRatio:   retry.BudgetRatio,
+			BudgetBurst:   retry.BudgetBurst,
+			MaxBodyBytes:  retry.MaxBodyBytes,
+		},
+		Admin: Admin{
+			Host: admin.Host,
+			Port: admin.Port,
+		},
+	}
+}
+
+// Load reads the config file at path, YAML or JSON depending on its extension.
+// Unknown fields are rejected so a typo does not silently keep a default.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(cfg)
+	default:
+		return nil, fmt.Errorf("%w: %s is neither a YAML nor a JSON file", errs.ErrInvalidConfig, path)
+	}
+
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %s is empty", errs.ErrInvalidConfig, path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errs.ErrInvalidConfig, path, err)
+	}
+
+	return cfg, nil
+}
@@ -6,5 +6,31 @@ var (
 	ErrUnsupportedAlg       = errors.New("unsupported algorithm")
 	ErrNoTargetServersFound = errors.New("no target servers found")
 
-	ErrInvalidBackendUrl = errors.New("invalid backend url")
+	ErrInvalidBackendUrl   = errors.New("invalid backend url")
+	ErrUnexpectedStatus    = errors.New("unexpected status code")
+	ErrInvalidTrustedProxy = errors.New("invalid trusted proxy")
+	ErrInvalidProxyHeader  = errors.New("invalid forwarding header")
+	ErrInvalidVirtualNodes = errors.New("virtual nodes must be positive")
+	ErrInvalidTableSize    = errors.New("table size must be a prime larger than the number of backends")
+	ErrInvalidLoadFactor   = errors.New("load factor must be greater than 1")
+	ErrInvalidLatencyDecay = errors.New("latency decay must be positive")
+
+	ErrInvalidResourceWeights = errors.New("resource weights must be non-negative with a positive sum")
+	ErrInvalidStaleAfter      = errors.New("report stale after must be positive")
+	ErrInvalidHealthCheck     = errors.New("invalid health check config")
+	ErrNoHealthyBackend       = errors.New("no healthy backend available")
+
+	ErrInvalidOutlierDetection = errors.New("invalid outlier detection config")
+	ErrInvalidCircuitBreaker   = errors.New("invalid circuit breaker config")
+	ErrInvalidRetry            = errors.New("invalid retry config")
+	ErrInvalidHedging          = errors.New("invalid hedging config")
+
+	ErrInvalidWeight   = errors.New("weight must be at least 1")
+	ErrBackendExists   = errors.New("backend already in the pool")
+	ErrBackendNotFound = errors.New("backend not in the pool")
+	ErrLastBackend     = errors.New("cannot remove the last backend")
+	ErrInvalidMode     = errors.New("invalid backend mode")
+	ErrInvalidAdmin    = errors.New("invalid admin config")
+	ErrInvalidListener = errors.New("invalid listener config")
+	ErrInvalidConfig   = errors.New("invalid config")
 )
@@ -0,0 +1,396 @@
+package loadbalancer
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// adminMaxBodyBytes bounds the JSON documents the admin API accepts
+const adminMaxBodyBytes = 1 << 20
+
+// AdminConfig configures the admin API. It is served on its own listener so it
+// can stay private while the load balancer itself is public.
+type AdminConfig struct {
+	Host string
+	Port int
+	// Token, when set, must be sent as a bearer token with every request
+	Token string
+}
+
+// DefaultAdminConfig serves the admin API on localhost only.
+func DefaultAdminConfig() AdminConfig {
+	return AdminConfig{
+		Host: "localhost",
+		Port: 9090,
+	}
+}
+
+func (c AdminConfig) validate() error {
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("%w: port %d is out of range", errs.ErrInvalidAdmin, c.Port)
+	}
+
+	return nil
+}
+
+// adminAPI lets operators inspect and change the pool and the algorithm of a
+// running load balancer through JSON endpoints.
+type adminAPI struct {
+	lb     *loadBalancer
+	config AdminConfig
+	server *http.Server
+}
+
+func newAdminAPI(lb *loadBalancer, config AdminConfig) (*adminAPI, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	api := &adminAPI{lb: lb, config: config}
+	api.server = &http.Server{
+		Addr:         net.JoinHostPort(config.Host, strconv.Itoa(config.Port)),
+		Handler:      api.routes(),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	return api, nil
+}
+
+func (api *adminAPI) routes() http.Handler {
+	router := mux.NewRouter()
+	router.Use(api.authenticate)
+
+	router.HandleFunc("/backends", api.listBackends).Methods(http.MethodGet)
+	router.HandleFunc("/backends", api.addBackend).Methods(http.MethodPost)
+	router.HandleFunc("/backends/{id}", api.getBackend).Methods(http.MethodGet)
+	router.HandleFunc("/backends/{id}", api.removeBackend).Methods(http.MethodDelete)
+	router.HandleFunc("/backends/{id}/weight", api.setWeight).Methods(http.MethodPut)
+	router.HandleFunc("/backends/{id}/mode", api.setMode).Methods(http.MethodPut)
+	router.HandleFunc("/algorithm", api.getAlgorithm).Methods(http.MethodGet)
+	router.HandleFunc("/algorithm", api.setAlgorithm).Methods(http.MethodPut)
+	router.HandleFunc("/health", api.health).Methods(http.MethodGet)
+
+	return router
+}
+
+func (api *adminAPI) Start() {
+	go func() {
+		if err := api.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("failed to start admin api")
+		}
+	}()
+
+	log.Info().Msgf("admin api running on %v", api.server.Addr)
+}
+
+func (api *adminAPI) Stop(ctx context.Context) error {
+	return api.server.Shutdown(ctx)
+}
+
+// authenticate rejects requests without the configured bearer token.
+func (api *adminAPI) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if api.config.Token != "" {
+			expected := []byte("Bearer " + api.config.Token)
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeJSON(w, http.StatusUnauthorized, errorView{Error: http.StatusText(http.StatusUnauthorized)})
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type errorView struct {
+	Error string `json:"error"`
+}
+
+type backendHealth struct {
+	// Available tells whether the backend takes new requests at all
+	Available bool   `json:"available"`
+	Healthy   bool   `json:"healthy"`
+	Ejected   bool   `json:"ejected"`
+	Circuit   string `json:"circuit,omitempty"`
+}
+
+type backendView struct {
+	ID     string        `json:"id"`
+	URL    string        `json:"url"`
+	Weight int           `json:"weight"`
+	Mode   pool.Mode     `json:"mode"`
+	Health backendHealth `json:"health"`
+	Stats  BackendStats  `json:"stats"`
+}
+
+type healthView struct {
+	Status    string        `json:"status"`
+	Available int           `json:"available"`
+	Total     int           `json:"total"`
+	Backends  []backendView `json:"backends"`
+}
+
+type addBackendRequest struct {
+	URL    string `json:"url"`
+	Weight *int   `json:"weight"`
+}
+
+type weightRequest struct {
+	Weight int `json:"weight"`
+}
+
+type modeRequest struct {
+	Mode pool.Mode `json:"mode"`
+}
+
+type algorithmView struct {
+	Algorithm Algorithm  `json:"algorithm"`
+	Previous  *Algorithm `json:"previous,omitempty"`
+}
+
+type algorithmRequest struct {
+	Algorithm Algorithm `json:"algorithm"`
+	// WarmStart defaults to true, the new algorithm starts from the state of
+	// the previous one
+	WarmStart *bool `json:"warm_start"`
+}
+
+func (api *adminAPI) listBackends(w http.ResponseWriter, _ *http.Request) {
+	backends := api.lb.handler.pool.Backends()
+
+	views := make([]backendView, 0, len(backends))
+	for _, b := range backends {
+		views = append(views, api.view(b))
+	}
+
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (api *adminAPI) getBackend(w http.ResponseWriter, r *http.Request) {
+	b, err := api.lookup(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.view(b))
+}
+
+func (api *adminAPI) addBackend(w http.ResponseWriter, r *http.Request) {
+	var req addBackendRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		writeError(w, fmt.Errorf("%w: %q", errs.ErrInvalidBackendUrl, req.URL))
+		return
+	}
+
+	weight := 1
+	if req.Weight != nil {
+		weight = *req.Weight
+	}
+
+	if err := api.lb.AddBackend(target, weight); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	b, _ := api.lb.handler.pool.Get(target)
+	writeJSON(w, http.StatusCreated, api.view(b))
+}
+
+func (api *adminAPI) removeBackend(w http.ResponseWriter, r *http.Request) {
+	b, err := api.lookup(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := api.lb.RemoveBackend(b.GetUrl()); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *adminAPI) setWeight(w http.ResponseWriter, r *http.Request) {
+	b, err := api.lookup(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req weightRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := api.lb.SetBackendWeight(b.GetUrl(), req.Weight); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.view(b))
+}
+
+func (api *adminAPI) setMode(w http.ResponseWriter, r *http.Request) {
+	b, err := api.lookup(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req modeRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := api.lb.SetBackendMode(b.GetUrl(), req.Mode); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.view(b))
+}
+
+func (api *adminAPI) getAlgorithm(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, algorithmView{Algorithm: api.lb.handler.algorithm.Load().kind})
+}
+
+func (api *adminAPI) setAlgorithm(w http.ResponseWriter, r *http.Request) {
+	var req algorithmRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	warmStart := req.WarmStart == nil || *req.WarmStart
+	previous, err := api.lb.SwitchAlgorithm(req.Algorithm, warmStart)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, algorithmView{Algorithm: req.Algorithm, Previous: &previous})
+}
+
+// health sums up whether the pool can serve, it answers 503 when no backend
+// takes requests so it doubles as a probe for whatever monitors the balancer.
+func (api *adminAPI) health(w http.ResponseWriter, _ *http.Request) {
+	backends := api.lb.handler.pool.Backends()
+
+	view := healthView{Total: len(backends), Backends: make([]backendView, 0, len(backends))}
+	for _, b := range backends {
+		backend := api.view(b)
+		if backend.Health.Available {
+			view.Available++
+		}
+		view.Backends = append(view.Backends, backend)
+	}
+
+	status := http.StatusOK
+	switch view.Available {
+	case view.Total:
+		view.Status = "ok"
+	case 0:
+		view.Status = "down"
+		status = http.StatusServiceUnavailable
+	default:
+		view.Status = "degraded"
+	}
+
+	writeJSON(w, status, view)
+}
+
+// lookup finds the backend named by the id of the path, the same opaque id
+// sticky sessions use.
+func (api *adminAPI) lookup(r *http.Request) (*pool.Backend, error) {
+	id := mux.Vars(r)["id"]
+	for _, b := range api.lb.handler.pool.Backends() {
+		if backendID(b.GetUrl()) == id {
+			return b, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", errs.ErrBackendNotFound, id)
+}
+
+func (api *adminAPI) view(b *pool.Backend) backendView {
+	hdl := api.lb.handler
+	target := b.GetUrl()
+
+	view := backendView{
+		ID:     backendID(target),
+		URL:    target.String(),
+		Weight: b.GetWeight(),
+		Mode:   b.Mode(),
+		Health: backendHealth{
+			Available: hdl.usable(target),
+			Healthy:   hdl.health == nil || hdl.health.Healthy(target),
+			Ejected:   hdl.outlier != nil && hdl.outlier.Ejected(target),
+		},
+	}
+
+	if hdl.breakers != nil {
+		view.Health.Circuit = hdl.breakers.State(target).String()
+	}
+
+	if hdl.stats != nil {
+		view.Stats = hdl.stats.snapshot(target)
+	}
+
+	return view
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, adminMaxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("failed to write admin response")
+	}
+}
+
+// writeError answers with the status matching err, anything unknown is blamed
+// on the request.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, errs.ErrBackendNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errs.ErrBackendExists), errors.Is(err, errs.ErrLastBackend):
+		status = http.StatusConflict
+	}
+
+	writeJSON(w, status, errorView{Error: err.Error()})
+}
@@ -0,0 +1,62 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/algorithms"
+)
+
+// AlgorithmParams tunes the algorithms taking parameters, each algorithm uses
+// the ones it understands.
+type AlgorithmParams struct {
+	// VirtualNodes is the number of ring points per weight unit of the ring
+	// hash algorithms
+	VirtualNodes    int
+	MaglevTableSize int
+	// LoadFactor caps the in-flight requests of a bounded load hash backend
+	// to this factor of its fair share
+	LoadFactor float64
+	// LatencyDecay is the time constant of the latency averages
+	LatencyDecay     time.Duration
+	ResourceWeights  algorithms.ResourceWeights
+	ReportStaleAfter time.Duration
+	// HashKey derives the key the hash based algorithms route on, nil routes
+	// on the client IP as do requests the key is missing from
+	HashKey algorithms.KeyExtractor
+	// Seed makes the random algorithms reproducible, 0 seeds them at random
+	Seed uint64
+}
+
+// DefaultAlgorithmParams are the parameters the algorithms run with unless
+// told otherwise.
+func DefaultAlgorithmParams() AlgorithmParams {
+	return AlgorithmParams{
+		VirtualNodes:     algorithms.DefaultVirtualNodes,
+		MaglevTableSize:  algorithms.DefaultMaglevTableSize,
+		LoadFactor:       algorithms.DefaultLoadFactor,
+		LatencyDecay:     algorithms.DefaultLatencyDecay,
+		ResourceWeights:  algorithms.DefaultResourceWeights,
+		ReportStaleAfter: algorithms.DefaultReportStaleAfter,
+	}
+}
+
+// SetAlgorithmParams rebuilds the active algorithm with params, which later
+// switches use as well. Must be called before Start.
+func (lb *loadBalancer) SetAlgorithmParams(params AlgorithmParams) error {
+	hdl := lb.handler
+	previous := hdl.params
+	hdl.params = params
+
+	// Every algorithm is built once so the parameters are checked up front,
+	// not when switching to an algorithm later
+	for alg, name := range algorithmNames {
+		if _, err := hdl.getAlgorithmImpl(alg); err != nil {
+			hdl.params = previous
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	_, err := hdl.switchAlgorithm(hdl.algorithm.Load().kind, false)
+	return err
+}
@@ -0,0 +1,94 @@
+package loadbalancer
+
+import (
+	"net/url"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/rs/zerolog/log"
+)
+
+// newBackendPool seeds a pool with the demo servers and their weights.
+func newBackendPool(targets []*backend.SimpleHTTPServer) (*pool.Pool, error) {
+	if len(targets) == 0 {
+		return nil, errs.ErrNoTargetServersFound
+	}
+
+	backends := pool.New()
+	for _, target := range targets {
+		if target == nil {
+			return nil, errs.ErrNoTargetServersFound
+		}
+
+		if err := backends.Add(target.GetUrl(), max(target.GetWeight(), 1)); err != nil {
+			return nil, err
+		}
+	}
+
+	return backends, nil
+}
+
+// AddBackend puts a new backend into the rotation while the load balancer is
+// serving.
+func (lb *loadBalancer) AddBackend(target *url.URL, weight int) error {
+	if err := lb.handler.pool.Add(target, weight); err != nil {
+		return err
+	}
+
+	log.Info().Str("backend", target.String()).Int("weight", weight).Msg("backend added")
+	return nil
+}
+
+// RemoveBackend takes target out of the rotation. Requests already forwarded
+// to it complete normally, draining it first lets them finish before.
+func (lb *loadBalancer) RemoveBackend(target *url.URL) error {
+	if err := lb.handler.pool.Remove(target); err != nil {
+		return err
+	}
+	lb.handler.forget(target)
+
+	log.Info().Str("backend", target.String()).Msg("backend removed")
+	return nil
+}
+
+// SetBackendWeight changes the share of the traffic target receives from the
+// weighted algorithms.
+func (lb *loadBalancer) SetBackendWeight(target *url.URL, weight int) error {
+	if err := lb.handler.pool.SetWeight(target, weight); err != nil {
+		return err
+	}
+
+	log.Info().Str("backend", target.String()).Int("weight", weight).Msg("backend weight changed")
+	return nil
+}
+
+// SwitchAlgorithm balances the new requests with alg, the requests in flight
+// finish on the previous algorithm which is returned. With warmStart alg
+// starts from what the previous algorithm learned about the backends.
+func (lb *loadBalancer) SwitchAlgorithm(alg Algorithm, warmStart bool) (Algorithm, error) {
+	previous, err := lb.handler.switchAlgorithm(alg, warmStart)
+	if err != nil {
+		return 0, err
+	}
+
+	log.Info().Stringer("from", previous.kind).Stringer("to", alg).Bool("warm_start", warmStart).
+		Int64("in_flight", previous.inFlight.Load()).Msg("algorithm switched")
+	return previous.kind, nil
+}
+
+// SetBackendMode stops or resumes sending new requests to target, the ones in
+// flight are not affected. A backend put in maintenance forgets its health and
+// failure history, so it starts over once active again.
+func (lb *loadBalancer) SetBackendMode(target *url.URL, mode pool.Mode) error {
+	if err := lb.handler.pool.SetMode(target, mode); err != nil {
+		return err
+	}
+
+	if mode == pool.ModeMaintenance {
+		lb.handler.forget(target)
+	}
+
+	log.Info().Str("backend", target.String()).Stringer("mode", mode).Msg("backend mode changed")
+	return nil
+}
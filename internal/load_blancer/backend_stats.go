@@ -0,0 +1,133 @@
+package loadbalancer
+
+import (
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+)
+
+// statsLatencyAlpha is the EWMA smoothing factor of the reported response times
+const statsLatencyAlpha = 0.2
+
+// backendCounters are the live numbers of a single backend.
+type backendCounters struct {
+	inFlight atomic.Int64
+	requests atomic.Int64
+	failures atomic.Int64
+	latency  atomic.Uint64 // float64 bits, nanoseconds
+}
+
+// BackendStats is a snapshot of the traffic a backend received.
+type BackendStats struct {
+	InFlight  int64   `json:"in_flight"`
+	Requests  int64   `json:"requests"`
+	Failures  int64   `json:"failures"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// backendStats counts the requests of every backend. Failures are 5xx answers
+// and unreachable backends.
+type backendStats struct {
+	pool     *pool.Pool
+	counters map[string]*backendCounters
+	mutex    sync.Mutex
+}
+
+func newBackendStats(backends *pool.Pool) *backendStats {
+	return &backendStats{
+		pool:     backends,
+		counters: make(map[string]*backendCounters, backends.Len()),
+	}
+}
+
+// acquire counts one more request in flight to target, the returned func must
+// be called once it completed.
+func (bs *backendStats) acquire(target *url.URL) func() {
+	counters, ok := bs.backend(target)
+	if !ok {
+		return func() {}
+	}
+	counters.inFlight.Add(1)
+	counters.requests.Add(1)
+
+	return func() {
+		counters.inFlight.Add(-1)
+	}
+}
+
+func (bs *backendStats) ObserveResponse(target *url.URL, status int, elapsed time.Duration) {
+	counters, ok := bs.backend(target)
+	if !ok {
+		return
+	}
+
+	if status >= http.StatusInternalServerError {
+		counters.failures.Add(1)
+	}
+
+	for {
+		old := counters.latency.Load()
+		latency := math.Float64frombits(old)
+		if latency == 0 {
+			latency = float64(elapsed)
+		} else {
+			latency += statsLatencyAlpha * (float64(elapsed) - latency)
+		}
+
+		if counters.latency.CompareAndSwap(old, math.Float64bits(latency)) {
+			return
+		}
+	}
+}
+
+func (bs *backendStats) ObserveError(target *url.URL, _ error, _ time.Duration) {
+	if counters, ok := bs.backend(target); ok {
+		counters.failures.Add(1)
+	}
+}
+
+func (bs *backendStats) snapshot(target *url.URL) BackendStats {
+	counters, ok := bs.backend(target)
+	if !ok {
+		return BackendStats{}
+	}
+
+	return BackendStats{
+		InFlight:  counters.inFlight.Load(),
+		Requests:  counters.requests.Load(),
+		Failures:  counters.failures.Load(),
+		LatencyMs: math.Float64frombits(counters.latency.Load()) / float64(time.Millisecond),
+	}
+}
+
+// forget drops the numbers of a backend removed from the pool.
+func (bs *backendStats) forget(target *url.URL) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	delete(bs.counters, target.String())
+}
+
+// backend returns the counters of target, false once it left the pool.
+func (bs *backendStats) backend(target *url.URL) (*backendCounters, bool) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	// Checked under the mutex so counters are never created after forget
+	if _, ok := bs.pool.Get(target); !ok {
+		return nil, false
+	}
+
+	counters, ok := bs.counters[target.String()]
+	if !ok {
+		counters = &backendCounters{}
+		bs.counters[target.String()] = counters
+	}
+
+	return counters, true
+}
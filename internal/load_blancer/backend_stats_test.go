@@ -0,0 +1,35 @@
+package loadbalancer
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackendStatsForgetRemovedBackend(t *testing.T) {
+	backends := newTestPool(t, 2)
+	stats := newBackendStats(backends)
+	target := backends.Backends()[1].GetUrl()
+
+	release := stats.acquire(target)
+	stats.ObserveResponse(target, http.StatusBadGateway, 10*time.Millisecond)
+	if snapshot := stats.snapshot(target); snapshot.Requests != 1 || snapshot.Failures != 1 {
+		t.Fatalf("stats %+v, expected 1 request and 1 failure", snapshot)
+	}
+
+	if err := backends.Remove(target); err != nil {
+		t.Fatal(err)
+	}
+	stats.forget(target)
+
+	// The request in flight completes after the backend left the pool
+	stats.ObserveResponse(target, http.StatusOK, 10*time.Millisecond)
+	stats.ObserveError(target, errors.New("connection reset"), 10*time.Millisecond)
+	stats.acquire(target)()
+	release()
+
+	if len(stats.counters) != 0 {
+		t.Fatalf("counters of %d backends kept, expected none", len(stats.counters))
+	}
+}
@@ -0,0 +1,342 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/rs/zerolog/log"
+)
+
+// circuitWindowBuckets is how many buckets the rolling window is split into,
+// outcomes expire one bucket at a time
+const circuitWindowBuckets = 10
+
+// minCircuitWindow is the shortest window accepted, below it the buckets get
+// too narrow to hold a meaningful number of outcomes
+const minCircuitWindow = 10 * time.Millisecond
+
+// CircuitBreakerConfig configures the per backend circuit breakers. A breaker
+// opens once FailureRateThreshold of at least MinRequests outcomes within
+// Window failed, rejects traffic for OpenTimeout, then lets
+// HalfOpenMaxRequests trial requests decide whether to close again.
+type CircuitBreakerConfig struct {
+	FailureRateThreshold float64
+	MinRequests          int
+	Window               time.Duration
+	OpenTimeout          time.Duration
+	HalfOpenMaxRequests  int
+	// SlowCallDuration counts responses slower than this as failures, zero
+	// only counts 5xx and unreachable backends
+	SlowCallDuration time.Duration
+}
+
+// DefaultCircuitBreakerConfig opens on half of the requests failing over ten
+// seconds.
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureRateThreshold: 0.5,
+		MinRequests:          10,
+		Window:               10 * time.Second,
+		OpenTimeout:          15 * time.Second,
+		HalfOpenMaxRequests:  3,
+		SlowCallDuration:     2 * time.Second,
+	}
+}
+
+func (c CircuitBreakerConfig) validate() error {
+	switch {
+	case c.FailureRateThreshold <= 0 || c.FailureRateThreshold > 1:
+		return fmt.Errorf("%w: failure rate threshold must be within (0, 1]", errs.ErrInvalidCircuitBreaker)
+	case c.MinRequests <= 0:
+		return fmt.Errorf("%w: min requests must be positive", errs.ErrInvalidCircuitBreaker)
+	case c.Window < minCircuitWindow:
+		return fmt.Errorf("%w: window must be at least %v", errs.ErrInvalidCircuitBreaker, minCircuitWindow)
+	case c.OpenTimeout <= 0:
+		return fmt.Errorf("%w: open timeout must be positive", errs.ErrInvalidCircuitBreaker)
+	case c.HalfOpenMaxRequests <= 0:
+		return fmt.Errorf("%w: half open max requests must be positive", errs.ErrInvalidCircuitBreaker)
+	case c.SlowCallDuration < 0:
+		return fmt.Errorf("%w: slow call duration must not be negative", errs.ErrInvalidCircuitBreaker)
+	}
+
+	return nil
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitClosed:
+		return "closed"
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return ""
+	}
+}
+
+type outcomeBucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// circuitBreaker guards a single backend.
+type circuitBreaker struct {
+	target   *url.URL
+	config   CircuitBreakerConfig
+	state    circuitState
+	openedAt time.Time
+	buckets  [circuitWindowBuckets]outcomeBucket
+
+	// Half open bookkeeping: trials running and trials that succeeded, the
+	// generation tells trials of an earlier half open period apart
+	trials         int
+	trialSuccesses int
+	generation     int
+
+	mutex sync.Mutex
+}
+
+// circuitBreakers holds one breaker per backend and listens to the outcome of
+// every proxied request. A breaker is created the first time its backend is
+// looked at.
+type circuitBreakers struct {
+	pool     *pool.Pool
+	config   CircuitBreakerConfig
+	breakers map[string]*circuitBreaker
+	mutex    sync.Mutex
+}
+
+func newCircuitBreakers(backends *pool.Pool, config CircuitBreakerConfig) (*circuitBreakers, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	cbs := &circuitBreakers{
+		pool:     backends,
+		config:   config,
+		breakers: make(map[string]*circuitBreaker, backends.Len()),
+	}
+
+	return cbs, nil
+}
+
+// breaker returns the breaker of target, false when target is not in the
+// pool.
+func (cbs *circuitBreakers) breaker(target *url.URL) (*circuitBreaker, bool) {
+	cbs.mutex.Lock()
+	defer cbs.mutex.Unlock()
+
+	// Checked under the mutex so a breaker is never created after forget
+	if _, ok := cbs.pool.Get(target); !ok {
+		return nil, false
+	}
+
+	cb, ok := cbs.breakers[target.String()]
+	if !ok {
+		cb = &circuitBreaker{target: target, config: cbs.config}
+		cbs.breakers[target.String()] = cb
+	}
+
+	return cb, true
+}
+
+// forget drops the breaker of a backend removed from the pool.
+func (cbs *circuitBreakers) forget(target *url.URL) {
+	cbs.mutex.Lock()
+	defer cbs.mutex.Unlock()
+
+	delete(cbs.breakers, target.String())
+}
+
+// Allows reports whether target may be picked, i.e. its breaker is closed or
+// has a trial request left.
+func (cbs *circuitBreakers) Allows(target *url.URL) bool {
+	cb, ok := cbs.breaker(target)
+	if !ok {
+		return true
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	return cb.allows(time.Now())
+}
+
+// acquire admits a request to target, the returned release must be called
+// once it completed. It fails when the last trial request of a half open
+// breaker was taken since target was picked.
+func (cbs *circuitBreakers) acquire(target *url.URL) (func(), bool) {
+	cb, ok := cbs.breaker(target)
+	if !ok {
+		return func() {}, true
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if !cb.allows(time.Now()) {
+		return nil, false
+	}
+
+	if cb.state != circuitHalfOpen {
+		return func() {}, true
+	}
+
+	cb.trials++
+	generation := cb.generation
+
+	return func() {
+		cb.mutex.Lock()
+		defer cb.mutex.Unlock()
+
+		if cb.generation == generation {
+			cb.trials--
+		}
+	}, true
+}
+
+// State returns the state of the breaker guarding target.
+func (cbs *circuitBreakers) State(target *url.URL) circuitState {
+	cb, ok := cbs.breaker(target)
+	if !ok {
+		return circuitClosed
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	return cb.state
+}
+
+func (cbs *circuitBreakers) ObserveResponse(target *url.URL, status int, elapsed time.Duration) {
+	failed := status >= http.StatusInternalServerError ||
+		(cbs.config.SlowCallDuration > 0 && elapsed > cbs.config.SlowCallDuration)
+	cbs.record(target, failed)
+}
+
+func (cbs *circuitBreakers) ObserveError(target *url.URL, _ error, _ time.Duration) {
+	cbs.record(target, true)
+}
+
+func (cbs *circuitBreakers) record(target *url.URL, failed bool) {
+	cb, ok := cbs.breaker(target)
+	if !ok {
+		return
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.record(time.Now(), failed)
+}
+
+// allows moves an open breaker to half open once its timeout elapsed. Callers
+// hold the mutex.
+func (cb *circuitBreaker) allows(now time.Time) bool {
+	if cb.state == circuitOpen && now.Sub(cb.openedAt) >= cb.config.OpenTimeout {
+		cb.transition(circuitHalfOpen, now)
+	}
+
+	switch cb.state {
+	case circuitClosed:
+		return true
+	case circuitHalfOpen:
+		return cb.trials < cb.config.HalfOpenMaxRequests
+	default:
+		return false
+	}
+}
+
+// record counts an outcome. Callers hold the mutex.
+func (cb *circuitBreaker) record(now time.Time, failed bool) {
+	switch cb.state {
+	case circuitOpen:
+		// Late answers of requests sent before opening change nothing
+		return
+	case circuitHalfOpen:
+		if failed {
+			cb.transition(circuitOpen, now)
+			return
+		}
+
+		cb.trialSuccesses++
+		if cb.trialSuccesses >= cb.config.HalfOpenMaxRequests {
+			cb.transition(circuitClosed, now)
+		}
+		return
+	}
+
+	bucket := cb.bucket(now)
+	if failed {
+		bucket.failures++
+	} else {
+		bucket.successes++
+	}
+
+	successes, failures := cb.totals(now)
+	total := successes + failures
+	if total >= cb.config.MinRequests &&
+		float64(failures) >= cb.config.FailureRateThreshold*float64(total) {
+		cb.transition(circuitOpen, now)
+	}
+}
+
+// bucket returns the bucket covering now, recycling the one it replaces.
+func (cb *circuitBreaker) bucket(now time.Time) *outcomeBucket {
+	width := cb.config.Window / circuitWindowBuckets
+	start := now.Truncate(width)
+	bucket := &cb.buckets[(start.UnixNano()/int64(width))%circuitWindowBuckets]
+
+	if !bucket.start.Equal(start) {
+		*bucket = outcomeBucket{start: start}
+	}
+
+	return bucket
+}
+
+// totals sums the buckets still inside the window.
+func (cb *circuitBreaker) totals(now time.Time) (int, int) {
+	successes, failures := 0, 0
+	for _, bucket := range cb.buckets {
+		if now.Sub(bucket.start) < cb.config.Window {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+
+	return successes, failures
+}
+
+func (cb *circuitBreaker) transition(state circuitState, now time.Time) {
+	log.Info().Str("backend", cb.target.String()).
+		Str("from", cb.state.String()).Str("to", state.String()).
+		Msg("circuit breaker state change")
+
+	cb.state = state
+	cb.trials = 0
+	cb.trialSuccesses = 0
+	cb.generation++
+
+	switch state {
+	case circuitOpen:
+		cb.openedAt = now
+	case circuitClosed:
+		// Start over so the failures that opened the breaker are forgotten
+		cb.buckets = [circuitWindowBuckets]outcomeBucket{}
+	}
+}
@@ -0,0 +1,213 @@
+package loadbalancer
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureRateThreshold: 0.5,
+		MinRequests:          4,
+		Window:               10 * time.Second,
+		OpenTimeout:          5 * time.Second,
+		HalfOpenMaxRequests:  2,
+	}
+}
+
+// newTestBreakers returns breakers over a pool of one backend, and that
+// backend's breaker.
+func newTestBreakers(t *testing.T) (*circuitBreakers, *circuitBreaker) {
+	t.Helper()
+
+	backends := newTestPool(t, 1)
+	cbs, err := newCircuitBreakers(backends, testCircuitBreakerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb, ok := cbs.breaker(backends.Backends()[0].GetUrl())
+	if !ok {
+		t.Fatal("no breaker for a backend of the pool")
+	}
+
+	return cbs, cb
+}
+
+func TestCircuitBreakerOpensAtThreshold(t *testing.T) {
+	tests := []struct {
+		name     string
+		outcomes []bool // true is a failure
+		expected circuitState
+	}{
+		{name: "below min requests", outcomes: []bool{true, true, true}, expected: circuitClosed},
+		{name: "all failed", outcomes: []bool{true, true, true, true}, expected: circuitOpen},
+		{name: "exactly the threshold", outcomes: []bool{false, false, true, true}, expected: circuitOpen},
+		{name: "below the threshold", outcomes: []bool{false, false, false, true}, expected: circuitClosed},
+		{name: "diluted by successes", outcomes: []bool{false, false, false, true, true}, expected: circuitClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cb := newTestBreakers(t)
+
+			now := time.Now()
+			for _, failed := range tt.outcomes {
+				cb.record(now, failed)
+			}
+
+			if cb.state != tt.expected {
+				t.Fatalf("state %v, expected %v", cb.state, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerWindowExpiry(t *testing.T) {
+	window := testCircuitBreakerConfig().Window
+
+	tests := []struct {
+		name     string
+		lastAt   time.Duration // after the first three failures
+		expected circuitState
+	}{
+		{name: "same bucket", lastAt: 0, expected: circuitOpen},
+		{name: "later bucket inside the window", lastAt: window / 2, expected: circuitOpen},
+		{name: "last bucket of the window", lastAt: window - window/circuitWindowBuckets, expected: circuitOpen},
+		{name: "first failures expired", lastAt: window, expected: circuitClosed},
+		{name: "long after", lastAt: 3 * window, expected: circuitClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cb := newTestBreakers(t)
+
+			start := time.Now().Truncate(window / circuitWindowBuckets)
+			for range 3 {
+				cb.record(start, true)
+			}
+			cb.record(start.Add(tt.lastAt), true)
+
+			if cb.state != tt.expected {
+				t.Fatalf("state %v, expected %v", cb.state, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpensAfterTimeout(t *testing.T) {
+	_, cb := newTestBreakers(t)
+	timeout := testCircuitBreakerConfig().OpenTimeout
+
+	now := time.Now()
+	cb.transition(circuitOpen, now)
+
+	if cb.allows(now.Add(timeout-time.Millisecond)) || cb.state != circuitOpen {
+		t.Fatalf("state %v before the open timeout, expected open and rejecting", cb.state)
+	}
+
+	if !cb.allows(now.Add(timeout)) || cb.state != circuitHalfOpen {
+		t.Fatalf("state %v after the open timeout, expected half-open and admitting", cb.state)
+	}
+}
+
+func TestCircuitBreakerHalfOpenAdmissionLimit(t *testing.T) {
+	cbs, cb := newTestBreakers(t)
+	target := cb.target
+	maxTrials := testCircuitBreakerConfig().HalfOpenMaxRequests
+
+	cb.transition(circuitOpen, time.Now().Add(-testCircuitBreakerConfig().OpenTimeout))
+
+	releases := make([]func(), 0, maxTrials)
+	for range maxTrials {
+		release, ok := cbs.acquire(target)
+		if !ok {
+			t.Fatal("trial request rejected below the limit")
+		}
+		releases = append(releases, release)
+	}
+
+	if _, ok := cbs.acquire(target); ok {
+		t.Fatal("trial request admitted above the limit")
+	}
+	if cbs.Allows(target) {
+		t.Fatal("backend allowed with every trial taken")
+	}
+
+	// A trial that completes without an outcome frees its slot
+	releases[0]()
+	if _, ok := cbs.acquire(target); !ok {
+		t.Fatal("trial request rejected after a slot was released")
+	}
+}
+
+func TestCircuitBreakerConcurrentTrials(t *testing.T) {
+	cbs, cb := newTestBreakers(t)
+	cb.transition(circuitOpen, time.Now().Add(-testCircuitBreakerConfig().OpenTimeout))
+
+	var admitted atomic.Int64
+	var wg sync.WaitGroup
+	for range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := cbs.acquire(cb.target); ok {
+				admitted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := admitted.Load(); got != int64(testCircuitBreakerConfig().HalfOpenMaxRequests) {
+		t.Fatalf("%d trials admitted, expected %d", got, testCircuitBreakerConfig().HalfOpenMaxRequests)
+	}
+}
+
+func TestCircuitBreakerTrialOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		outcomes []bool // true is a failure
+		expected circuitState
+	}{
+		{name: "failed trial reopens", outcomes: []bool{true}, expected: circuitOpen},
+		{name: "failure after a success reopens", outcomes: []bool{false, true}, expected: circuitOpen},
+		{name: "one success is not enough", outcomes: []bool{false}, expected: circuitHalfOpen},
+		{name: "every trial succeeded closes", outcomes: []bool{false, false}, expected: circuitClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cbs, cb := newTestBreakers(t)
+			cb.transition(circuitOpen, time.Now().Add(-testCircuitBreakerConfig().OpenTimeout))
+
+			for _, failed := range tt.outcomes {
+				release, ok := cbs.acquire(cb.target)
+				if !ok {
+					t.Fatal("trial request rejected")
+				}
+				cbs.record(cb.target, failed)
+				release()
+			}
+
+			if state := cbs.State(cb.target); state != tt.expected {
+				t.Fatalf("state %v, expected %v", state, tt.expected)
+			}
+
+			// A reopened breaker waits a full timeout again
+			if tt.expected == circuitOpen && cbs.Allows(cb.target) {
+				t.Fatal("reopened breaker admits requests")
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerConfigRejectsShortWindow(t *testing.T) {
+	config := testCircuitBreakerConfig()
+	config.Window = time.Millisecond
+
+	if err := config.validate(); err == nil {
+		t.Fatal("window of 1ms accepted")
+	}
+}
@@ -0,0 +1,226 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/rs/zerolog/log"
+)
+
+// HealthCheckConfig configures the active health checks. A backend turns
+// unhealthy after UnhealthyThreshold failed probes in a row and healthy again
+// after HealthyThreshold successful ones.
+type HealthCheckConfig struct {
+	Path               string
+	Interval           time.Duration
+	Timeout            time.Duration
+	ExpectedStatus     int
+	HealthyThreshold   int
+	UnhealthyThreshold int
+}
+
+// DefaultHealthCheckConfig probes the health endpoint of the demo backends.
+func DefaultHealthCheckConfig() HealthCheckConfig {
+	return HealthCheckConfig{
+		Path:               backend.HealthPath,
+		Interval:           2 * time.Second,
+		Timeout:            time.Second,
+		ExpectedStatus:     http.StatusOK,
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 2,
+	}
+}
+
+func (c HealthCheckConfig) validate() error {
+	switch {
+	case c.Interval <= 0:
+		return fmt.Errorf("%w: interval must be positive", errs.ErrInvalidHealthCheck)
+	case c.Timeout <= 0 || c.Timeout > c.Interval:
+		return fmt.Errorf("%w: timeout must be positive and at most the interval", errs.ErrInvalidHealthCheck)
+	case c.ExpectedStatus < 100 || c.ExpectedStatus > 599:
+		return fmt.Errorf("%w: expected status %d is not an HTTP status", errs.ErrInvalidHealthCheck, c.ExpectedStatus)
+	case c.HealthyThreshold <= 0 || c.UnhealthyThreshold <= 0:
+		return fmt.Errorf("%w: thresholds must be positive", errs.ErrInvalidHealthCheck)
+	}
+
+	return nil
+}
+
+type healthState struct {
+	healthy   bool
+	successes int
+	failures  int
+}
+
+// healthChecker probes every backend on an interval and keeps whether it may
+// receive traffic. Backends start healthy so the balancer serves right away,
+// a dead one is taken out after UnhealthyThreshold intervals. Backends joining
+// the pool are probed from the next interval on.
+type healthChecker struct {
+	pool   *pool.Pool
+	config HealthCheckConfig
+	client *http.Client
+	states map[string]*healthState
+	mutex  sync.RWMutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newHealthChecker(backends *pool.Pool, config HealthCheckConfig) (*healthChecker, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	hc := &healthChecker{
+		pool:   backends,
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		states: make(map[string]*healthState, backends.Len()),
+	}
+
+	return hc, nil
+}
+
+// Start probes every backend right away and then once per interval until Stop.
+func (hc *healthChecker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	hc.cancel = cancel
+	hc.done = make(chan struct{})
+
+	go func() {
+		defer close(hc.done)
+
+		ticker := time.NewTicker(hc.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			hc.probeAll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (hc *healthChecker) Stop() {
+	if hc.cancel == nil {
+		return
+	}
+
+	hc.cancel()
+	<-hc.done
+}
+
+// Healthy reports whether target passes its health checks, backends not
+// probed yet are healthy.
+func (hc *healthChecker) Healthy(target *url.URL) bool {
+	hc.mutex.RLock()
+	defer hc.mutex.RUnlock()
+
+	state, ok := hc.states[target.String()]
+	return !ok || state.healthy
+}
+
+// forget drops the state of a backend removed from the pool.
+func (hc *healthChecker) forget(target *url.URL) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	delete(hc.states, target.String())
+}
+
+func (hc *healthChecker) probeAll(ctx context.Context) {
+	wg := sync.WaitGroup{}
+
+	for _, b := range hc.pool.Backends() {
+		if b.Mode() == pool.ModeMaintenance {
+			continue
+		}
+
+		target := b.GetUrl()
+		wg.Add(1)
+		go func(target *url.URL) {
+			defer wg.Done()
+
+			err := hc.probe(ctx, target)
+			if ctx.Err() != nil {
+				// Shutting down, the failure says nothing about the backend
+				return
+			}
+			hc.record(target, err)
+		}(target)
+	}
+
+	wg.Wait()
+}
+
+func (hc *healthChecker) probe(ctx context.Context, target *url.URL) error {
+	endpoint := target.JoinPath(hc.config.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint: errcheck
+
+	// Drain the body so the connection is reused by the next probe
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+
+	if resp.StatusCode != hc.config.ExpectedStatus {
+		return fmt.Errorf("%w: %d", errs.ErrUnexpectedStatus, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// record counts the probe result and flips the state once a threshold of
+// consecutive results is reached.
+func (hc *healthChecker) record(target *url.URL, probeErr error) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	// The backend left the pool or went into maintenance while it was probed
+	if b, ok := hc.pool.Get(target); !ok || b.Mode() == pool.ModeMaintenance {
+		return
+	}
+
+	state, ok := hc.states[target.String()]
+	if !ok {
+		state = &healthState{healthy: true}
+		hc.states[target.String()] = state
+	}
+
+	if probeErr == nil {
+		state.failures = 0
+		state.successes++
+		if !state.healthy && state.successes >= hc.config.HealthyThreshold {
+			state.healthy = true
+			log.Info().Str("backend", target.String()).Msg("backend is healthy again")
+		}
+		return
+	}
+
+	state.successes = 0
+	state.failures++
+	if state.healthy && state.failures >= hc.config.UnhealthyThreshold {
+		state.healthy = false
+		log.Warn().Err(probeErr).Str("backend", target.String()).Msg("backend is unhealthy")
+	}
+}
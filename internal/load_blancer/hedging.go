@@ -0,0 +1,320 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/algorithms"
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// hedgeLatencySamples is how many recent response times the percentile
+	// delay is computed over
+	hedgeLatencySamples = 512
+	// hedgeMinSamples is how many response times are needed before the
+	// percentile replaces the fixed delay
+	hedgeMinSamples = 50
+)
+
+// HedgingConfig configures request hedging: when the backend of an idempotent
+// request has not answered after a delay, a duplicate is sent to another one
+// and the first answer wins.
+type HedgingConfig struct {
+	// Delay is how long a backend has to answer before a hedge is sent
+	Delay time.Duration
+	// Percentile, e.g. 0.95, replaces Delay by that percentile of the recent
+	// response times once enough were observed, zero keeps Delay
+	Percentile float64
+	// MaxHedges is how many duplicates one request may get
+	MaxHedges int
+	// BudgetRatio is the share of requests that may be hedged, on top of a
+	// burst of BudgetBurst hedges
+	BudgetRatio float64
+	BudgetBurst int
+	// MaxBodyBytes is the largest request body buffered for duplicates,
+	// larger requests are not hedged
+	MaxBodyBytes int64
+}
+
+// DefaultHedgingConfig hedges the slowest 5% of the requests, at most one in
+// ten.
+func DefaultHedgingConfig() HedgingConfig {
+	return HedgingConfig{
+		Delay:        100 * time.Millisecond,
+		Percentile:   0.95,
+		MaxHedges:    1,
+		BudgetRatio:  0.1,
+		BudgetBurst:  5,
+		MaxBodyBytes: 64 << 10,
+	}
+}
+
+func (c HedgingConfig) validate() error {
+	switch {
+	case c.Delay <= 0:
+		return fmt.Errorf("%w: delay must be positive", errs.ErrInvalidHedging)
+	case c.Percentile < 0 || c.Percentile >= 1:
+		return fmt.Errorf("%w: percentile must be within [0, 1)", errs.ErrInvalidHedging)
+	case c.MaxHedges < 1:
+		return fmt.Errorf("%w: max hedges must be at least 1", errs.ErrInvalidHedging)
+	case c.BudgetRatio < 0 || c.BudgetBurst < 0:
+		return fmt.Errorf("%w: hedging budget must not be negative", errs.ErrInvalidHedging)
+	case c.MaxBodyBytes < 0:
+		return fmt.Errorf("%w: max body bytes must not be negative", errs.ErrInvalidHedging)
+	}
+
+	return nil
+}
+
+type hedger struct {
+	config    HedgingConfig
+	budget    *retryBudget
+	latencies *latencyWindow
+}
+
+func newHedger(config HedgingConfig) (*hedger, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return &hedger{
+		config:    config,
+		budget:    newRetryBudget(config.BudgetRatio, config.BudgetBurst),
+		latencies: &latencyWindow{},
+	}, nil
+}
+
+// delay returns how long to wait for an answer before hedging.
+func (hg *hedger) delay() time.Duration {
+	if hg.config.Percentile == 0 {
+		return hg.config.Delay
+	}
+
+	if delay, ok := hg.latencies.percentile(hg.config.Percentile); ok {
+		return delay
+	}
+
+	return hg.config.Delay
+}
+
+// serveHedged forwards r and sends duplicates to other backends while nobody
+// answered within the hedging delay. The first backend answering wins, the
+// others are cancelled.
+func (lb *loadBalanceHandler) serveHedged(w http.ResponseWriter, r *http.Request, impl AlgorithmImplementer) {
+	hg := lb.hedge
+	hg.budget.deposit()
+
+	body, replayable, err := bufferBody(r, hg.config.MaxBodyBytes)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	race := &hedgeRace{winner: -1}
+	finished := make(chan struct{}, hg.config.MaxHedges+1)
+	tried := make([]string, 0, hg.config.MaxHedges+1)
+
+	launch := func(filter func(*url.URL) bool) bool {
+		target, release := lb.nextBackend(r, impl, filter)
+		if target == nil {
+			return false
+		}
+		tried = append(tried, target.String())
+
+		ctx, cancel := context.WithCancelCause(r.Context())
+		idx := race.add(cancel)
+
+		outcome := &attemptOutcome{}
+		req := attemptRequest(lb.observed(r.WithContext(ctx)), body, outcome)
+		req = algorithms.WithProxyObserver(req, hg.latencies)
+		aw := &attemptWriter{
+			ResponseWriter: w,
+			discard: func(int) bool {
+				return !race.claim(idx, outcome.unreachable())
+			},
+		}
+
+		go func() {
+			defer func() { finished <- struct{}{} }()
+			defer release()
+			defer cancel(nil)
+			defer race.finish()
+			defer race.recoverAbort(idx)
+
+			impl.Forward(aw, req, target)
+		}()
+
+		return true
+	}
+
+	if !launch(lb.usable) {
+		log.Warn().Err(errs.ErrNoHealthyBackend).Str("path", r.URL.Path).Msg("rejecting request")
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	untried := func(candidate *url.URL) bool {
+		return !slices.Contains(tried, candidate.String()) && lb.usable(candidate)
+	}
+
+	timer := time.NewTimer(hg.delay())
+	defer timer.Stop()
+
+	for pending, hedges := 1, 0; pending > 0; {
+		select {
+		case <-finished:
+			pending--
+		case <-timer.C:
+			if !replayable || race.decided() || hedges >= hg.config.MaxHedges || !hg.budget.withdraw() {
+				continue
+			}
+
+			if launch(untried) {
+				log.Info().Str("path", r.URL.Path).Int("hedge", hedges+1).Msg("hedging request on another backend")
+				pending++
+			}
+
+			hedges++
+			if hedges < hg.config.MaxHedges {
+				timer.Reset(hg.delay())
+			}
+		}
+	}
+
+	// The winner lost the client half way, abort the response like the proxy
+	// would have done running on the handler goroutine
+	if race.winnerAborted() {
+		panic(http.ErrAbortHandler)
+	}
+
+	// Every attempt failed while another one was still running
+	if !race.decided() {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+// hedgeRace decides which attempt of a hedged request answers the client.
+type hedgeRace struct {
+	winner  int
+	running int
+	aborted bool
+	cancels []context.CancelCauseFunc
+	mutex   sync.Mutex
+}
+
+func (hr *hedgeRace) add(cancel context.CancelCauseFunc) int {
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	hr.cancels = append(hr.cancels, cancel)
+	hr.running++
+
+	return len(hr.cancels) - 1
+}
+
+func (hr *hedgeRace) finish() {
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	hr.running--
+}
+
+// recoverAbort stops the panic the reverse proxy raises when a response body
+// cannot be copied, which every cancelled loser does.
+func (hr *hedgeRace) recoverAbort(idx int) {
+	p := recover()
+	if p == nil {
+		return
+	}
+
+	if p != http.ErrAbortHandler { //nolint:errorlint
+		panic(p)
+	}
+
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	if hr.winner == idx {
+		hr.aborted = true
+	}
+}
+
+func (hr *hedgeRace) winnerAborted() bool {
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	return hr.aborted
+}
+
+func (hr *hedgeRace) decided() bool {
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	return hr.winner != -1
+}
+
+// claim lets attempt idx answer the client unless another one already does.
+// An attempt that could not reach its backend gives way while others run.
+// The losers are cancelled, which their observers do not count as failures.
+func (hr *hedgeRace) claim(idx int, failed bool) bool {
+	hr.mutex.Lock()
+	defer hr.mutex.Unlock()
+
+	if hr.winner != -1 {
+		return hr.winner == idx
+	}
+
+	if failed && hr.running > 1 {
+		return false
+	}
+
+	hr.winner = idx
+	for other, cancel := range hr.cancels {
+		if other != idx {
+			cancel(nil)
+		}
+	}
+
+	return true
+}
+
+// latencyWindow keeps the most recent response times to derive percentiles.
+type latencyWindow struct {
+	samples [hedgeLatencySamples]time.Duration
+	count   int
+	next    int
+	mutex   sync.Mutex
+}
+
+func (lw *latencyWindow) ObserveResponse(_ *url.URL, _ int, elapsed time.Duration) {
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+
+	lw.samples[lw.next] = elapsed
+	lw.next = (lw.next + 1) % len(lw.samples)
+	lw.count = min(lw.count+1, len(lw.samples))
+}
+
+func (lw *latencyWindow) ObserveError(*url.URL, error, time.Duration) {}
+
+// percentile returns the p-th percentile of the window, false until it holds
+// enough samples.
+func (lw *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	lw.mutex.Lock()
+	sorted := slices.Clone(lw.samples[:lw.count])
+	lw.mutex.Unlock()
+
+	if len(sorted) < hedgeMinSamples {
+		return 0, false
+	}
+
+	slices.Sort(sorted)
+	return sorted[int(p*float64(len(sorted)-1))], true
+}
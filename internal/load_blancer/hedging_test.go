@@ -0,0 +1,170 @@
+package loadbalancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/algorithms"
+)
+
+func testHedgingConfig() HedgingConfig {
+	config := DefaultHedgingConfig()
+	config.Delay = 20 * time.Millisecond
+	config.Percentile = 0
+	config.BudgetRatio = 1
+	config.BudgetBurst = 10
+
+	return config
+}
+
+// newHedgeHandler balances over one backend per handler with hedging, outlier
+// detection and circuit breakers on.
+func newHedgeHandler(
+	t *testing.T, config HedgingConfig, handlers ...http.HandlerFunc,
+) (*loadBalanceHandler, []*testBackend) {
+	t.Helper()
+
+	backends, servers := newTestBackends(t, handlers...)
+	hdl := newTestHandler(t, backends)
+
+	hg, err := newHedger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdl.hedge = hg
+
+	if hdl.outlier, err = newOutlierDetector(backends, DefaultOutlierDetectionConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if hdl.breakers, err = newCircuitBreakers(backends, DefaultCircuitBreakerConfig()); err != nil {
+		t.Fatal(err)
+	}
+
+	return hdl, servers
+}
+
+// dropAfter returns a handler closing the connection without answering after
+// delay, which the proxy sees as an unreachable backend.
+func dropAfter(delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(delay)
+
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}
+}
+
+func TestHedgingFirstAnswerWins(t *testing.T) {
+	hdl, servers := newHedgeHandler(t, testHedgingConfig(),
+		answerAfter(5*time.Second, http.StatusOK, "slow"), answer(http.StatusOK, "fast"))
+	slow := servers[0]
+
+	observer := newRecordingObserver()
+	for range 4 {
+		r := algorithms.WithProxyObserver(httptest.NewRequest(http.MethodGet, "/", nil), observer)
+		w := httptest.NewRecorder()
+
+		start := time.Now()
+		hdl.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK || w.Body.String() != "fast" {
+			t.Fatalf("answered %d %q, expected the fast backend", w.Code, w.Body.String())
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("answered after %v, the slow backend was waited for", elapsed)
+		}
+	}
+
+	if slow.hits.Load() == 0 {
+		t.Fatal("the slow backend never started a race")
+	}
+
+	// The cancelled losers are no failure of the slow backend
+	if errs := observer.errorsOf(slow.url); len(errs) != 0 {
+		t.Fatalf("losers reported as errors: %v", errs)
+	}
+
+	cb, _ := hdl.breakers.breaker(slow.url)
+	cb.mutex.Lock()
+	_, failures := cb.totals(time.Now())
+	cb.mutex.Unlock()
+	if failures != 0 {
+		t.Fatalf("circuit breaker counted %d failures", failures)
+	}
+
+	hdl.outlier.mutex.Lock()
+	state, ok := hdl.outlier.states[slow.url.String()]
+	hdl.outlier.mutex.Unlock()
+	if ok && state.consecutiveErrors != 0 {
+		t.Fatalf("outlier detector counted %d gateway errors", state.consecutiveErrors)
+	}
+}
+
+func TestHedgingFailedAttemptGivesWay(t *testing.T) {
+	hdl, servers := newHedgeHandler(t, testHedgingConfig(),
+		dropAfter(50*time.Millisecond), answerAfter(100*time.Millisecond, http.StatusOK, "hedge"))
+
+	// Round robin makes the dropping backend the first attempt once and the
+	// hedge once, either way it fails while the other one still runs
+	for range 2 {
+		w := httptest.NewRecorder()
+		hdl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != http.StatusOK || w.Body.String() != "hedge" {
+			t.Fatalf("answered %d %q, expected the running attempt to win", w.Code, w.Body.String())
+		}
+	}
+
+	if servers[0].hits.Load() == 0 {
+		t.Fatal("the dropping backend was never tried")
+	}
+}
+
+func TestHedgingCaps(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxHedges int
+		ratio     float64
+		burst     int
+		requests  int
+		hits      int64
+	}{
+		{name: "one hedge", maxHedges: 1, ratio: 1, burst: 10, requests: 1, hits: 2},
+		{name: "two hedges", maxHedges: 2, ratio: 1, burst: 10, requests: 1, hits: 3},
+		{name: "budget of one hedge", maxHedges: 3, ratio: 0, burst: 1, requests: 2, hits: 3},
+		{name: "no budget", maxHedges: 3, ratio: 0, burst: 0, requests: 2, hits: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testHedgingConfig()
+			config.MaxHedges = tt.maxHedges
+			config.BudgetRatio = tt.ratio
+			config.BudgetBurst = tt.burst
+
+			slow := answerAfter(200*time.Millisecond, http.StatusOK, "slow")
+			hdl, servers := newHedgeHandler(t, config, slow, slow, slow, slow)
+
+			for range tt.requests {
+				w := httptest.NewRecorder()
+				hdl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+				if w.Code != http.StatusOK {
+					t.Fatalf("answered %d, expected 200", w.Code)
+				}
+			}
+
+			var hits int64
+			for _, server := range servers {
+				hits += server.hits.Load()
+			}
+			if hits != tt.hits {
+				t.Fatalf("backends got %d requests, expected %d", hits, tt.hits)
+			}
+		})
+	}
+}
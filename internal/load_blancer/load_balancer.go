@@ -1,6 +1,7 @@
 package loadbalancer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -8,6 +9,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/DucTran999/load-balancing-algo/internal/clientip"
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
 	"github.com/DucTran999/load-balancing-algo/pkg/backend"
 	"github.com/rs/zerolog/log"
 )
@@ -28,11 +32,74 @@ func (a Algorithm) String() string {
 		return "Lowest Response Time"
 	case ResourceBase:
 		return "Resource Base"
+	case PowerOfTwoChoices:
+		return "Power of Two Choices"
+	case ConsistentHash:
+		return "Consistent Hash"
+	case Maglev:
+		return "Maglev Hash"
+	case RendezvousHash:
+		return "Rendezvous Hash"
+	case BoundedLoadHash:
+		return "Bounded Load Hash"
+	case SmoothWeightedRoundRobin:
+		return "Smooth Weighted Round Robin"
+	case WeightedLeastConnection:
+		return "Weighted Least Connection"
+	case PeakEWMA:
+		return "Peak EWMA"
+	case WeightedResourceBase:
+		return "Weighted Resource Base"
+	case Random:
+		return "Random"
+	case WeightedRandom:
+		return "Weighted Random"
 	default:
 		return ""
 	}
 }
 
+// algorithmNames are the names algorithms go by in JSON, e.g. on the admin API.
+var algorithmNames = map[Algorithm]string{
+	RoundRobin:               "round-robin",
+	WeightedRoundRobin:       "weighted-round-robin",
+	SourceIPHash:             "source-ip-hash",
+	LeastConnection:          "least-connection",
+	LowestLatency:            "lowest-latency",
+	ResourceBase:             "resource-base",
+	PowerOfTwoChoices:        "power-of-two-choices",
+	ConsistentHash:           "consistent-hash",
+	Maglev:                   "maglev",
+	RendezvousHash:           "rendezvous-hash",
+	BoundedLoadHash:          "bounded-load-hash",
+	SmoothWeightedRoundRobin: "smooth-weighted-round-robin",
+	WeightedLeastConnection:  "weighted-least-connection",
+	PeakEWMA:                 "peak-ewma",
+	WeightedResourceBase:     "weighted-resource-base",
+	Random:                   "random",
+	WeightedRandom:           "weighted-random",
+}
+
+func (a Algorithm) MarshalText() ([]byte, error) {
+	name, ok := algorithmNames[a]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", errs.ErrUnsupportedAlg, a)
+	}
+
+	return []byte(name), nil
+}
+
+func (a *Algorithm) UnmarshalText(text []byte) error {
+	for alg, name := range algorithmNames {
+		if name == string(text) {
+			*a = alg
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q", errs.ErrUnsupportedAlg, text)
+}
+
 const (
 	RoundRobin Algorithm = iota
 	WeightedRoundRobin
@@ -40,16 +107,72 @@ const (
 	LeastConnection
 	LowestLatency
 	ResourceBase
+	PowerOfTwoChoices
+	ConsistentHash
+	Maglev
+	RendezvousHash
+	BoundedLoadHash
+	SmoothWeightedRoundRobin
+	WeightedLeastConnection
+	PeakEWMA
+	WeightedResourceBase
+	Random
+	WeightedRandom
 )
 
 type LoadBalancer interface {
 	Start() error
+	Stop(ctx context.Context) error
+}
+
+// ListenerConfig is where the load balancer accepts traffic and how long it
+// waits on its clients.
+type ListenerConfig struct {
+	Host         string
+	Port         int
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// TrustedProxies are the CIDR ranges whose forwarding headers are believed
+	// when resolving the client address, nil trusts no proxy
+	TrustedProxies []string
+	// ProxyHeader is the forwarding header the trusted proxies set, the only
+	// one read. Empty reads X-Forwarded-For
+	ProxyHeader string
+}
+
+// DefaultListenerConfig listens on host and port with conservative timeouts.
+func DefaultListenerConfig(host string, port int) ListenerConfig {
+	return ListenerConfig{
+		Host:           host,
+		Port:           port,
+		ReadTimeout:    10 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		IdleTimeout:    60 * time.Second,
+		TrustedProxies: clientip.DefaultTrustedProxies,
+		ProxyHeader:    clientip.HeaderXForwardedFor,
+	}
+}
+
+func (c ListenerConfig) validate() error {
+	switch {
+	case c.Port <= 0 || c.Port > 65535:
+		return fmt.Errorf("%w: port %d is out of range", errs.ErrInvalidListener, c.Port)
+	case c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0:
+		return fmt.Errorf("%w: timeouts must not be negative", errs.ErrInvalidListener)
+	}
+
+	return nil
 }
 
 type loadBalancer struct {
-	port   int
-	host   string
-	server *http.Server
+	port    int
+	host    string
+	server  *http.Server
+	handler *loadBalanceHandler
+	poller  *loadPoller
+	health  *healthChecker
+	admin   *adminAPI
 }
 
 func NewLoadBalancer(
@@ -57,26 +180,135 @@ func NewLoadBalancer(
 	targets []*backend.SimpleHTTPServer,
 	alg Algorithm,
 ) (*loadBalancer, error) {
-	hdl, err := NewLoadBalancerHandler(alg, targets)
+	backends, err := newBackendPool(targets)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLoadBalancerWithConfig(DefaultListenerConfig(host, port), backends, alg)
+}
+
+// NewLoadBalancerWithConfig balances the backends of a pool the caller built,
+// e.g. from a config file, on the given listener.
+func NewLoadBalancerWithConfig(
+	listener ListenerConfig, backends *pool.Pool, alg Algorithm,
+) (*loadBalancer, error) {
+	if err := listener.validate(); err != nil {
+		return nil, err
+	}
+
+	hdl, err := NewLoadBalancerHandler(alg, backends, listener.ProxyHeader, listener.TrustedProxies)
 	if err != nil {
 		return nil, err
 	}
 
 	lb := &loadBalancer{
-		host: host,
-		port: port,
+		host:    listener.Host,
+		port:    listener.Port,
+		handler: hdl,
 		server: &http.Server{
-			Addr:         net.JoinHostPort(host, strconv.Itoa(port)),
+			Addr:         net.JoinHostPort(listener.Host, strconv.Itoa(listener.Port)),
 			Handler:      hdl,
-			ReadTimeout:  10 * time.Second,
-			WriteTimeout: 10 * time.Second,
-			IdleTimeout:  60 * time.Second,
+			ReadTimeout:  listener.ReadTimeout,
+			WriteTimeout: listener.WriteTimeout,
+			IdleTimeout:  listener.IdleTimeout,
 		},
 	}
 
+	// Algorithms balancing on backend load get it polled out of band as well,
+	// whichever algorithm is active at the time
+	lb.poller = newLoadPoller(backends, hdl.loadReportObserver, DefaultLoadPollInterval)
+
 	return lb, nil
 }
 
+// EnableStickySession pins every client to the backend the algorithm picked
+// for its first request, must be called before Start.
+func (lb *loadBalancer) EnableStickySession(config StickySessionConfig) error {
+	current := lb.handler.algorithm.Load()
+	sticky, err := newStickySession(current.impl, lb.handler.pool, config)
+	if err != nil {
+		return err
+	}
+	lb.handler.algorithm.Store(&activeAlgorithm{kind: current.kind, impl: sticky})
+
+	return nil
+}
+
+// EnableHealthCheck probes the backends actively and keeps unhealthy ones out
+// of every algorithm, must be called before Start.
+func (lb *loadBalancer) EnableHealthCheck(config HealthCheckConfig) error {
+	health, err := newHealthChecker(lb.handler.pool, config)
+	if err != nil {
+		return err
+	}
+	lb.health = health
+	lb.handler.health = health
+
+	return nil
+}
+
+// EnableOutlierDetection ejects backends whose proxied responses misbehave for
+// a growing period, must be called before Start.
+func (lb *loadBalancer) EnableOutlierDetection(config OutlierDetectionConfig) error {
+	outlier, err := newOutlierDetector(lb.handler.pool, config)
+	if err != nil {
+		return err
+	}
+	lb.handler.outlier = outlier
+
+	return nil
+}
+
+// EnableCircuitBreaker guards every backend with a circuit breaker, requests
+// avoid backends whose breaker is open. Must be called before Start.
+func (lb *loadBalancer) EnableCircuitBreaker(config CircuitBreakerConfig) error {
+	breakers, err := newCircuitBreakers(lb.handler.pool, config)
+	if err != nil {
+		return err
+	}
+	lb.handler.breakers = breakers
+
+	return nil
+}
+
+// EnableRetry retries failed idempotent requests on other backends, must be
+// called before Start.
+func (lb *loadBalancer) EnableRetry(config RetryConfig) error {
+	retry, err := newRetrier(config)
+	if err != nil {
+		return err
+	}
+	lb.handler.retry = retry
+
+	return nil
+}
+
+// EnableHedging duplicates slow idempotent requests to another backend and
+// keeps the first answer, must be called before Start.
+func (lb *loadBalancer) EnableHedging(config HedgingConfig) error {
+	hedge, err := newHedger(config)
+	if err != nil {
+		return err
+	}
+	lb.handler.hedge = hedge
+
+	return nil
+}
+
+// EnableAdmin serves the admin API on its own listener and starts counting
+// the requests of every backend for it, must be called before Start.
+func (lb *loadBalancer) EnableAdmin(config AdminConfig) error {
+	admin, err := newAdminAPI(lb, config)
+	if err != nil {
+		return err
+	}
+	lb.admin = admin
+	lb.handler.stats = newBackendStats(lb.handler.pool)
+
+	return nil
+}
+
 func (lb *loadBalancer) Start() error {
 	// Start HTTP server in a goroutine
 	go func() {
@@ -101,6 +333,36 @@ func (lb *loadBalancer) Start() error {
 		time.Sleep(100 * time.Millisecond) // prevent tight loop
 	}
 
+	if lb.poller != nil {
+		lb.poller.Start()
+	}
+
+	if lb.health != nil {
+		lb.health.Start()
+	}
+
+	if lb.admin != nil {
+		lb.admin.Start()
+	}
+
 	log.Info().Msgf("load balancer running on %v", address)
 	return nil
 }
+
+func (lb *loadBalancer) Stop(ctx context.Context) error {
+	if lb.poller != nil {
+		lb.poller.Stop()
+	}
+
+	if lb.health != nil {
+		lb.health.Stop()
+	}
+
+	if lb.admin != nil {
+		if err := lb.admin.Stop(ctx); err != nil {
+			log.Warn().Err(err).Msg("failed to stop admin api")
+		}
+	}
+
+	return lb.server.Shutdown(ctx)
+}
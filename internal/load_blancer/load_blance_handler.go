@@ -1,34 +1,87 @@
 package loadbalancer
 
 import (
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
 
 	"github.com/DucTran999/load-balancing-algo/internal/algorithms"
+	"github.com/DucTran999/load-balancing-algo/internal/clientip"
 	"github.com/DucTran999/load-balancing-algo/internal/errs"
-	"github.com/DucTran999/load-balancing-algo/pkg/backend"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/rs/zerolog/log"
 )
 
 type AlgorithmImplementer interface {
+	// ForwardRequest chooses a backend and forwards r to it on its own, or
+	// answers 503 when no backend is usable
 	ForwardRequest(w http.ResponseWriter, r *http.Request)
+
+	// NextBackend chooses the backend for r among those accepted by filter
+	// without forwarding it, nil means none is usable. The request counts on
+	// the chosen backend until the returned release is called
+	NextBackend(r *http.Request, filter algorithms.Filter) (*url.URL, func())
+
+	// Forward proxies r to target, which the caller may have chosen itself
+	Forward(w http.ResponseWriter, r *http.Request, target *url.URL)
+}
+
+// activeAlgorithm is the algorithm new requests are balanced with. Once
+// switched out it serves the requests it started until they complete.
+type activeAlgorithm struct {
+	kind     Algorithm
+	impl     AlgorithmImplementer
+	inFlight atomic.Int64
+	retired  atomic.Bool
+}
+
+// acquire counts a request balanced with the algorithm, the returned release
+// must be called once it completed.
+func (a *activeAlgorithm) acquire() func() {
+	a.inFlight.Add(1)
+
+	return func() {
+		if a.inFlight.Add(-1) == 0 && a.retired.Load() {
+			log.Info().Stringer("algorithm", a.kind).Msg("switched out algorithm drained")
+		}
+	}
 }
 
 type loadBalanceHandler struct {
-	targets       []*backend.SimpleHTTPServer
-	algorithmImpl AlgorithmImplementer
+	pool        *pool.Pool
+	clientIP    *clientip.Resolver
+	params      AlgorithmParams
+	algorithm   atomic.Pointer[activeAlgorithm]
+	switchMutex sync.Mutex
+	health      *healthChecker
+	outlier     *outlierDetector
+	breakers    *circuitBreakers
+	retry       *retrier
+	hedge       *hedger
+	stats       *backendStats
 }
 
 func NewLoadBalancerHandler(
-	alg Algorithm, targets []*backend.SimpleHTTPServer,
+	alg Algorithm, backends *pool.Pool, proxyHeader string, trustedProxies []string,
 ) (*loadBalanceHandler, error) {
+	resolver, err := clientip.NewResolver(proxyHeader, trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
 	hdl := &loadBalanceHandler{
-		targets: targets,
+		pool:     backends,
+		clientIP: resolver,
+		params:   DefaultAlgorithmParams(),
 	}
 
 	algorithmImpl, err := hdl.getAlgorithmImpl(alg)
 	if err != nil {
 		return nil, err
 	}
-	hdl.algorithmImpl = algorithmImpl
+	hdl.algorithm.Store(&activeAlgorithm{kind: alg, impl: algorithmImpl})
 
 	if err = hdl.validateConfig(); err != nil {
 		return nil, err
@@ -38,38 +91,261 @@ func NewLoadBalancerHandler(
 }
 
 func (lb *loadBalanceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	lb.algorithmImpl.ForwardRequest(w, r)
+	// Resolving the client address walks the forwarding headers, which is
+	// only worth it when the line is logged
+	if e := log.Debug(); e.Enabled() {
+		e.Str("client_ip", lb.clientIP.ClientIP(r)).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Msg("incoming request")
+	}
+
+	// The request sticks to the algorithm it started with, even when another
+	// one is switched in meanwhile
+	active := lb.algorithm.Load()
+	done := active.acquire()
+	defer done()
+	impl := active.impl
+
+	// A hedged request is already sent to several backends, it is not retried
+	if lb.hedge != nil && idempotent(r.Method) {
+		lb.serveHedged(w, r, impl)
+		return
+	}
+
+	if lb.retry != nil && lb.retry.eligible(r) {
+		lb.serveWithRetries(w, r, impl)
+		return
+	}
+
+	target, release := lb.nextBackend(r, impl, lb.usable)
+	if target == nil {
+		log.Warn().Err(errs.ErrNoHealthyBackend).Str("path", r.URL.Path).Msg("rejecting request")
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	impl.Forward(w, lb.observed(r), target)
+}
+
+// observed returns a copy of r whose outcome is reported to the backend stats,
+// the outlier detector and the circuit breakers. Every attempt of a request is
+// observed on its own, timed from the moment it is sent.
+func (lb *loadBalanceHandler) observed(r *http.Request) *http.Request {
+	if lb.stats != nil {
+		r = algorithms.WithProxyObserver(r, lb.stats)
+	}
+
+	if lb.outlier != nil {
+		r = algorithms.WithProxyObserver(r, lb.outlier)
+	}
+
+	if lb.breakers != nil {
+		r = algorithms.WithProxyObserver(r, lb.breakers)
+	}
+
+	return r
+}
+
+// nextBackend picks the backend for r and admits the request through its
+// circuit breaker. A backend whose last trial request was taken in between is
+// left out and the algorithm picks another one.
+func (lb *loadBalanceHandler) nextBackend(
+	r *http.Request, impl AlgorithmImplementer, filter algorithms.Filter,
+) (*url.URL, func()) {
+	for range lb.pool.Len() {
+		target, reserved := impl.NextBackend(r, filter)
+		if target == nil {
+			return nil, nil
+		}
+
+		if release, ok := lb.admit(target); ok {
+			return target, func() {
+				release()
+				reserved()
+			}
+		}
+		reserved()
+
+		previous := filter
+		filter = func(candidate *url.URL) bool {
+			return candidate.String() != target.String() && previous.Allows(candidate)
+		}
+	}
+
+	return nil, nil
+}
+
+// admit lets a request to target through its circuit breaker and counts it in
+// flight, the returned release must be called once it completed.
+func (lb *loadBalanceHandler) admit(target *url.URL) (func(), bool) {
+	release := func() {}
+	if lb.breakers != nil {
+		var ok bool
+		if release, ok = lb.breakers.acquire(target); !ok {
+			return nil, false
+		}
+	}
+
+	if lb.stats != nil {
+		releaseBreaker, done := release, lb.stats.acquire(target)
+		release = func() {
+			done()
+			releaseBreaker()
+		}
+	}
+
+	return release, true
+}
+
+// usable reports whether target may receive new requests.
+func (lb *loadBalanceHandler) usable(target *url.URL) bool {
+	// Algorithms may still pick from the members they saw before a change
+	if b, ok := lb.pool.Get(target); !ok || b.Mode() != pool.ModeActive {
+		return false
+	}
+
+	if lb.health != nil && !lb.health.Healthy(target) {
+		return false
+	}
+
+	if lb.outlier != nil && lb.outlier.Ejected(target) {
+		return false
+	}
+
+	if lb.breakers != nil && !lb.breakers.Allows(target) {
+		return false
+	}
+
+	return true
 }
 
 func (h *loadBalanceHandler) getAlgorithmImpl(alg Algorithm) (AlgorithmImplementer, error) {
+	params := h.params
+	// Requests without the configured key fall back to the client IP, resolved
+	// through the trusted proxies like the source IP key
+	hashKey := algorithms.SourceIPKey(h.clientIP)
+	if params.HashKey != nil {
+		hashKey = algorithms.FallbackKey(params.HashKey, hashKey)
+	}
+	seed := params.Seed
+	if seed == 0 {
+		seed = rand.Uint64() //nolint:gosec
+	}
+
 	switch alg {
 	case RoundRobin:
-		return algorithms.NewRoundRobinAlg(h.targets)
+		return algorithms.NewRoundRobinAlg(h.pool)
 	case WeightedRoundRobin:
-		return algorithms.NewWeightedRoundRobinAlg(h.targets)
+		return algorithms.NewWeightedRoundRobinAlg(h.pool)
 	case SourceIPHash:
-		return algorithms.NewSourceIPHashAlgorithm(h.targets)
+		return algorithms.NewSourceIPHashAlgorithm(h.pool, hashKey)
 	case LowestLatency:
-		return algorithms.NewLowestLatencyAlg(h.targets)
+		return algorithms.NewLowestLatencyAlg(h.pool, params.LatencyDecay)
 	case LeastConnection:
-		return algorithms.NewLeastConnectionAlg(h.targets)
+		return algorithms.NewLeastConnectionAlg(h.pool)
 	case ResourceBase:
-		return algorithms.NewResourceBaseLoadAlg(h.targets)
+		return algorithms.NewResourceBaseLoadAlg(h.pool)
+	case PowerOfTwoChoices:
+		return algorithms.NewPowerOfTwoChoicesAlg(h.pool)
+	case ConsistentHash:
+		return algorithms.NewConsistentHashAlg(h.pool, params.VirtualNodes, hashKey)
+	case Maglev:
+		return algorithms.NewMaglevAlg(h.pool, params.MaglevTableSize, hashKey)
+	case RendezvousHash:
+		return algorithms.NewRendezvousHashAlg(h.pool, hashKey)
+	case BoundedLoadHash:
+		return algorithms.NewBoundedLoadHashAlg(h.pool, params.VirtualNodes, params.LoadFactor, hashKey)
+	case SmoothWeightedRoundRobin:
+		return algorithms.NewSmoothWeightedRoundRobinAlg(h.pool)
+	case WeightedLeastConnection:
+		return algorithms.NewWeightedLeastConnectionAlg(h.pool)
+	case PeakEWMA:
+		return algorithms.NewPeakEWMAAlg(h.pool, params.LatencyDecay)
+	case WeightedResourceBase:
+		return algorithms.NewWeightedResourceLoadAlg(
+			h.pool, params.ResourceWeights, params.ReportStaleAfter, params.LatencyDecay,
+		)
+	case Random:
+		return algorithms.NewRandomAlg(h.pool, seed)
+	case WeightedRandom:
+		return algorithms.NewWeightedRandomAlg(h.pool, seed)
 	default:
 		return nil, errs.ErrUnsupportedAlg
 	}
 }
 
-func (lb *loadBalanceHandler) validateConfig() error {
-	if len(lb.targets) == 0 {
-		return errs.ErrNoTargetServersFound
+// switchAlgorithm balances the new requests with alg while the ones in flight
+// finish on the previous algorithm, which is returned. With warmStart the new
+// algorithm starts from the state the previous one gathered, e.g. the
+// latencies or the requests in flight. Session affinity carries over.
+func (lb *loadBalanceHandler) switchAlgorithm(alg Algorithm, warmStart bool) (*activeAlgorithm, error) {
+	impl, err := lb.getAlgorithmImpl(alg)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, s := range lb.targets {
-		if s == nil {
-			return errs.ErrNoTargetServersFound
+	lb.switchMutex.Lock()
+	defer lb.switchMutex.Unlock()
+
+	previous := lb.algorithm.Load()
+	if warmStart {
+		from, fromOk := unwrapSticky(previous.impl).(algorithms.WarmStarter)
+		to, toOk := impl.(algorithms.WarmStarter)
+		if fromOk && toOk {
+			to.ImportState(from.ExportState())
 		}
 	}
 
+	if sticky, ok := previous.impl.(*stickySession); ok {
+		impl = sticky.wrap(impl)
+	}
+	lb.algorithm.Store(&activeAlgorithm{kind: alg, impl: impl})
+	previous.retired.Store(true)
+
+	return previous, nil
+}
+
+// loadReportObserver returns the active algorithm when it balances on the load
+// the backends report, nil otherwise.
+func (lb *loadBalanceHandler) loadReportObserver() LoadReportObserver {
+	observer, _ := unwrapSticky(lb.algorithm.Load().impl).(LoadReportObserver)
+	return observer
+}
+
+// unwrapSticky returns the algorithm session affinity is layered on.
+func unwrapSticky(impl AlgorithmImplementer) AlgorithmImplementer {
+	if sticky, ok := impl.(*stickySession); ok {
+		return sticky.next
+	}
+
+	return impl
+}
+
+// forget drops what the handler learned about a backend removed from the pool.
+func (lb *loadBalanceHandler) forget(target *url.URL) {
+	if lb.health != nil {
+		lb.health.forget(target)
+	}
+
+	if lb.outlier != nil {
+		lb.outlier.forget(target)
+	}
+
+	if lb.breakers != nil {
+		lb.breakers.forget(target)
+	}
+
+	if lb.stats != nil {
+		lb.stats.forget(target)
+	}
+}
+
+func (lb *loadBalanceHandler) validateConfig() error {
+	if lb.pool == nil || lb.pool.Len() == 0 {
+		return errs.ErrNoTargetServersFound
+	}
+
 	return nil
 }
@@ -0,0 +1,138 @@
+package loadbalancer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/DucTran999/load-balancing-algo/pkg/loadreport"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultLoadPollInterval = 2 * time.Second
+	DefaultLoadPollTimeout  = time.Second
+)
+
+// LoadReportObserver is implemented by algorithms that balance on the load the
+// backends report about themselves.
+type LoadReportObserver interface {
+	ObserveLoadReport(target *url.URL, report loadreport.Report)
+}
+
+// loadPoller scrapes the load endpoint of every backend on an interval and
+// hands the reports to the observer, so backends stay measured even while they
+// receive no traffic. Nothing is polled while observer returns nil.
+type loadPoller struct {
+	pool     *pool.Pool
+	observer func() LoadReportObserver
+	interval time.Duration
+	client   *http.Client
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func newLoadPoller(
+	backends *pool.Pool, observer func() LoadReportObserver, interval time.Duration,
+) *loadPoller {
+	return &loadPoller{
+		pool:     backends,
+		observer: observer,
+		interval: interval,
+		client:   &http.Client{Timeout: DefaultLoadPollTimeout},
+	}
+}
+
+// Start polls every backend right away and then once per interval until Stop.
+func (p *loadPoller) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			p.pollAll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *loadPoller) Stop() {
+	if p.cancel == nil {
+		return
+	}
+
+	p.cancel()
+	<-p.done
+}
+
+func (p *loadPoller) pollAll(ctx context.Context) {
+	observer := p.observer()
+	if observer == nil {
+		return
+	}
+
+	wg := sync.WaitGroup{}
+
+	for _, b := range p.pool.Backends() {
+		if b.Mode() == pool.ModeMaintenance {
+			continue
+		}
+
+		target := b.GetUrl()
+		wg.Add(1)
+		go func(target *url.URL) {
+			defer wg.Done()
+
+			report, err := p.poll(ctx, target)
+			if err != nil {
+				log.Warn().Err(err).Str("backend", target.String()).Msg("failed to poll backend load")
+				return
+			}
+			observer.ObserveLoadReport(target, report)
+		}(target)
+	}
+
+	wg.Wait()
+}
+
+func (p *loadPoller) poll(ctx context.Context, target *url.URL) (loadreport.Report, error) {
+	endpoint := target.JoinPath(loadreport.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return loadreport.Report{}, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return loadreport.Report{}, err
+	}
+	defer resp.Body.Close() //nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return loadreport.Report{}, fmt.Errorf("%w: %d", errs.ErrUnexpectedStatus, resp.StatusCode)
+	}
+
+	var doc loadreport.Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return loadreport.Report{}, err
+	}
+
+	return doc.Report(), nil
+}
@@ -0,0 +1,148 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/rs/zerolog"
+)
+
+// TestMain silences the per request logs of the load balancer and algorithms.
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// newTestPool returns a pool of n backends http://backend-<i> of weight 1.
+func newTestPool(t *testing.T, n int) *pool.Pool {
+	t.Helper()
+
+	backends := pool.New()
+	for idx := range n {
+		target, err := url.Parse(fmt.Sprintf("http://backend-%d", idx))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := backends.Add(target, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return backends
+}
+
+// testBackend is a real backend served by httptest that counts its requests.
+type testBackend struct {
+	url    *url.URL
+	hits   atomic.Int64
+	server *httptest.Server
+}
+
+// newTestBackends starts one backend per handler and returns the pool of them,
+// in the same order.
+func newTestBackends(t *testing.T, handlers ...http.HandlerFunc) (*pool.Pool, []*testBackend) {
+	t.Helper()
+
+	backends := pool.New()
+	servers := make([]*testBackend, 0, len(handlers))
+	for _, handler := range handlers {
+		tb := &testBackend{}
+		tb.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tb.hits.Add(1)
+			handler(w, r)
+		}))
+		t.Cleanup(tb.server.Close)
+
+		target, err := url.Parse(tb.server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tb.url = target
+
+		if err := backends.Add(target, 1); err != nil {
+			t.Fatal(err)
+		}
+		servers = append(servers, tb)
+	}
+
+	return backends, servers
+}
+
+// newTestHandler balances backends round robin with no optional feature on.
+func newTestHandler(t *testing.T, backends *pool.Pool) *loadBalanceHandler {
+	t.Helper()
+
+	hdl, err := NewLoadBalancerHandler(RoundRobin, backends, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hdl
+}
+
+// answer returns a handler answering status with body.
+func answer(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+// answerAfter returns a handler answering status with body after delay, or
+// nothing when the request is canceled first.
+func answerAfter(delay time.Duration, status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			answer(status, body)(w, r)
+		case <-r.Context().Done():
+		}
+	}
+}
+
+// recordingObserver remembers the outcomes it hears, per backend.
+type recordingObserver struct {
+	mutex     sync.Mutex
+	responses map[string][]int
+	errors    map[string][]error
+}
+
+func newRecordingObserver() *recordingObserver {
+	return &recordingObserver{
+		responses: make(map[string][]int),
+		errors:    make(map[string][]error),
+	}
+}
+
+func (o *recordingObserver) ObserveResponse(target *url.URL, status int, _ time.Duration) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.responses[target.String()] = append(o.responses[target.String()], status)
+}
+
+func (o *recordingObserver) ObserveError(target *url.URL, err error, _ time.Duration) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.errors[target.String()] = append(o.errors[target.String()], err)
+}
+
+func (o *recordingObserver) errorsOf(target *url.URL) []error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return o.errors[target.String()]
+}
@@ -0,0 +1,258 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// latencyOutlierSamples is how many responses a backend needs before its
+	// latency is compared with the pool
+	latencyOutlierSamples = 10
+	// latencyOutlierPeers is how many measured backends make a median worth
+	// comparing with
+	latencyOutlierPeers = 3
+	// latencyOutlierAlpha is the EWMA smoothing factor of the response times
+	latencyOutlierAlpha = 0.2
+)
+
+// OutlierDetectionConfig configures the passive health checks. A zero
+// threshold disables the matching detection.
+type OutlierDetectionConfig struct {
+	// Consecutive5xx ejects a backend answering that many 5xx in a row
+	Consecutive5xx int
+	// ConsecutiveGatewayErrors ejects a backend that could not be reached
+	// that many times in a row
+	ConsecutiveGatewayErrors int
+	// SlowFactor ejects a backend whose average response time exceeds the
+	// median of the pool by this factor
+	SlowFactor float64
+	// BaseEjectionTime is the first ejection period, it doubles on every
+	// ejection of the same backend up to MaxEjectionTime
+	BaseEjectionTime time.Duration
+	MaxEjectionTime  time.Duration
+	// MaxEjectionPercent caps the share of the pool ejected at once, the last
+	// backend is never ejected
+	MaxEjectionPercent int
+}
+
+// DefaultOutlierDetectionConfig follows the defaults of common proxies.
+func DefaultOutlierDetectionConfig() OutlierDetectionConfig {
+	return OutlierDetectionConfig{
+		Consecutive5xx:           5,
+		ConsecutiveGatewayErrors: 3,
+		SlowFactor:               3,
+		BaseEjectionTime:         30 * time.Second,
+		MaxEjectionTime:          5 * time.Minute,
+		MaxEjectionPercent:       50,
+	}
+}
+
+func (c OutlierDetectionConfig) validate() error {
+	switch {
+	case c.Consecutive5xx < 0 || c.ConsecutiveGatewayErrors < 0:
+		return fmt.Errorf("%w: consecutive thresholds must not be negative", errs.ErrInvalidOutlierDetection)
+	case c.SlowFactor != 0 && c.SlowFactor <= 1:
+		return fmt.Errorf("%w: slow factor must be greater than 1", errs.ErrInvalidOutlierDetection)
+	case c.BaseEjectionTime <= 0 || c.MaxEjectionTime < c.BaseEjectionTime:
+		return fmt.Errorf("%w: ejection times must be positive with max at least base", errs.ErrInvalidOutlierDetection)
+	case c.MaxEjectionPercent < 0 || c.MaxEjectionPercent > 100:
+		return fmt.Errorf("%w: max ejection percent must be within [0, 100]", errs.ErrInvalidOutlierDetection)
+	}
+
+	return nil
+}
+
+type outlierState struct {
+	consecutive5xx    int
+	consecutiveErrors int
+	latency           float64
+	samples           int
+	ejections         int
+	ejectedUntil      time.Time
+}
+
+// outlierDetector watches the responses proxied to every backend and ejects
+// the ones misbehaving for a period growing with each ejection.
+type outlierDetector struct {
+	pool   *pool.Pool
+	config OutlierDetectionConfig
+	states map[string]*outlierState
+	mutex  sync.Mutex
+}
+
+func newOutlierDetector(backends *pool.Pool, config OutlierDetectionConfig) (*outlierDetector, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	od := &outlierDetector{
+		pool:   backends,
+		config: config,
+		states: make(map[string]*outlierState, backends.Len()),
+	}
+
+	return od, nil
+}
+
+// Ejected reports whether target is currently kept out of the pool.
+func (od *outlierDetector) Ejected(target *url.URL) bool {
+	od.mutex.Lock()
+	defer od.mutex.Unlock()
+
+	state, ok := od.states[target.String()]
+	return ok && time.Now().Before(state.ejectedUntil)
+}
+
+// forget drops the state of a backend removed from the pool.
+func (od *outlierDetector) forget(target *url.URL) {
+	od.mutex.Lock()
+	defer od.mutex.Unlock()
+
+	delete(od.states, target.String())
+}
+
+func (od *outlierDetector) ObserveResponse(target *url.URL, status int, elapsed time.Duration) {
+	od.mutex.Lock()
+	defer od.mutex.Unlock()
+
+	state, ok := od.state(target)
+	if !ok {
+		return
+	}
+
+	state.consecutiveErrors = 0
+	if status >= http.StatusInternalServerError {
+		state.consecutive5xx++
+	} else {
+		state.consecutive5xx = 0
+	}
+
+	if state.samples == 0 {
+		state.latency = float64(elapsed)
+	} else {
+		state.latency += latencyOutlierAlpha * (float64(elapsed) - state.latency)
+	}
+	state.samples++
+
+	switch {
+	case od.config.Consecutive5xx > 0 && state.consecutive5xx >= od.config.Consecutive5xx:
+		od.eject(target, state, "consecutive 5xx")
+	case od.isSlow(state):
+		od.eject(target, state, "latency outlier")
+	}
+}
+
+func (od *outlierDetector) ObserveError(target *url.URL, _ error, _ time.Duration) {
+	od.mutex.Lock()
+	defer od.mutex.Unlock()
+
+	state, ok := od.state(target)
+	if !ok {
+		return
+	}
+
+	state.consecutiveErrors++
+	if od.config.ConsecutiveGatewayErrors > 0 && state.consecutiveErrors >= od.config.ConsecutiveGatewayErrors {
+		od.eject(target, state, "consecutive gateway errors")
+	}
+}
+
+// state returns the state of target, created on its first outcome. Outcomes
+// of a backend no longer in the pool are ignored. Callers hold the mutex.
+func (od *outlierDetector) state(target *url.URL) (*outlierState, bool) {
+	if _, ok := od.pool.Get(target); !ok {
+		return nil, false
+	}
+
+	state, ok := od.states[target.String()]
+	if !ok {
+		state = &outlierState{}
+		od.states[target.String()] = state
+	}
+
+	return state, true
+}
+
+// isSlow compares the average response time of state with the median of the
+// measured pool. Callers hold the mutex.
+func (od *outlierDetector) isSlow(state *outlierState) bool {
+	if od.config.SlowFactor == 0 || state.samples < latencyOutlierSamples {
+		return false
+	}
+
+	latencies := make([]float64, 0, len(od.states))
+	for _, peer := range od.states {
+		if peer.samples >= latencyOutlierSamples {
+			latencies = append(latencies, peer.latency)
+		}
+	}
+
+	if len(latencies) < latencyOutlierPeers {
+		return false
+	}
+
+	slices.Sort(latencies)
+	median := latencies[len(latencies)/2]
+
+	return state.latency > od.config.SlowFactor*median
+}
+
+// eject takes target out for BaseEjectionTime doubled per previous ejection,
+// unless the pool already lost as many backends as allowed. Callers hold the
+// mutex.
+func (od *outlierDetector) eject(target *url.URL, state *outlierState, reason string) {
+	now := time.Now()
+	if now.Before(state.ejectedUntil) {
+		return
+	}
+
+	// Forget old ejections once the backend behaved for a whole max period
+	if !state.ejectedUntil.IsZero() && now.Sub(state.ejectedUntil) > od.config.MaxEjectionTime {
+		state.ejections = 0
+	}
+
+	if od.ejectedCount(now)+1 > od.maxEjected() {
+		log.Warn().Str("backend", target.String()).Str("reason", reason).
+			Msg("outlier not ejected, max ejection percent reached")
+		return
+	}
+
+	period := od.config.BaseEjectionTime << min(state.ejections, 30)
+	if period <= 0 || period > od.config.MaxEjectionTime {
+		period = od.config.MaxEjectionTime
+	}
+
+	state.ejections++
+	state.ejectedUntil = now.Add(period)
+	state.consecutive5xx = 0
+	state.consecutiveErrors = 0
+	state.samples = 0
+
+	log.Warn().Str("backend", target.String()).Str("reason", reason).
+		Dur("period", period).Msg("backend ejected")
+}
+
+func (od *outlierDetector) ejectedCount(now time.Time) int {
+	count := 0
+	for _, state := range od.states {
+		if now.Before(state.ejectedUntil) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (od *outlierDetector) maxEjected() int {
+	members := od.pool.Len()
+	return min(members*od.config.MaxEjectionPercent/100, members-1)
+}
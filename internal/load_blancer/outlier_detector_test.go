@@ -0,0 +1,212 @@
+package loadbalancer
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var errTestUnreachable = errors.New("backend unreachable")
+
+func testOutlierDetectionConfig() OutlierDetectionConfig {
+	return OutlierDetectionConfig{
+		Consecutive5xx:           3,
+		ConsecutiveGatewayErrors: 2,
+		BaseEjectionTime:         time.Second,
+		MaxEjectionTime:          3 * time.Second,
+		MaxEjectionPercent:       50,
+	}
+}
+
+// newTestOutlierDetector returns a detector over a pool of n backends, and
+// their urls.
+func newTestOutlierDetector(t *testing.T, n int, config OutlierDetectionConfig) (*outlierDetector, []*url.URL) {
+	t.Helper()
+
+	backends := newTestPool(t, n)
+	od, err := newOutlierDetector(backends, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets := make([]*url.URL, 0, n)
+	for _, backend := range backends.Backends() {
+		targets = append(targets, backend.GetUrl())
+	}
+
+	return od, targets
+}
+
+// failUntilEjected reports as many 5xx as it takes to eject target.
+func failUntilEjected(od *outlierDetector, target *url.URL) {
+	for range od.config.Consecutive5xx {
+		od.ObserveResponse(target, http.StatusServiceUnavailable, time.Millisecond)
+	}
+}
+
+func TestOutlierDetectorConsecutiveFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		outcomes []int // a status, or 0 for a gateway error
+		expected bool
+	}{
+		{name: "5xx below threshold", outcomes: []int{500, 502}, expected: false},
+		{name: "5xx at threshold", outcomes: []int{500, 502, 503}, expected: true},
+		{name: "5xx interrupted", outcomes: []int{500, 502, 200, 503}, expected: false},
+		{name: "4xx are no failure", outcomes: []int{404, 429, 400}, expected: false},
+		{name: "gateway error below threshold", outcomes: []int{0}, expected: false},
+		{name: "gateway errors at threshold", outcomes: []int{0, 0}, expected: true},
+		{name: "gateway errors interrupted", outcomes: []int{0, 503, 0}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			od, targets := newTestOutlierDetector(t, 4, testOutlierDetectionConfig())
+
+			for _, status := range tt.outcomes {
+				if status == 0 {
+					od.ObserveError(targets[0], errTestUnreachable, time.Millisecond)
+				} else {
+					od.ObserveResponse(targets[0], status, time.Millisecond)
+				}
+			}
+
+			if ejected := od.Ejected(targets[0]); ejected != tt.expected {
+				t.Fatalf("ejected %v, expected %v", ejected, tt.expected)
+			}
+			if od.Ejected(targets[1]) {
+				t.Fatal("a healthy backend was ejected")
+			}
+		})
+	}
+}
+
+func TestOutlierDetectorEjectionPeriodDoubles(t *testing.T) {
+	config := testOutlierDetectionConfig()
+	od, targets := newTestOutlierDetector(t, 4, config)
+	target := targets[0]
+
+	expire := func(ago time.Duration) {
+		od.mutex.Lock()
+		defer od.mutex.Unlock()
+
+		od.states[target.String()].ejectedUntil = time.Now().Add(-ago)
+	}
+
+	// Each ejection right after the previous one doubles up to the max, a
+	// backend behaving for a whole max period starts over
+	periods := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second, time.Second}
+	for idx, period := range periods {
+		if idx == len(periods)-1 {
+			expire(config.MaxEjectionTime + time.Second)
+		} else if idx > 0 {
+			expire(time.Millisecond)
+		}
+
+		before := time.Now()
+		failUntilEjected(od, target)
+		after := time.Now()
+
+		until := od.states[target.String()].ejectedUntil
+		if until.Before(before.Add(period)) || until.After(after.Add(period)) {
+			t.Fatalf("ejection %d lasts %v, expected %v", idx, until.Sub(before), period)
+		}
+	}
+}
+
+func TestOutlierDetectorMaxEjected(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends int
+		percent  int
+		expected int
+	}{
+		{name: "half of four", backends: 4, percent: 50, expected: 2},
+		{name: "rounded down", backends: 5, percent: 50, expected: 2},
+		{name: "below one backend", backends: 3, percent: 10, expected: 0},
+		{name: "all but the last", backends: 4, percent: 100, expected: 3},
+		{name: "single backend", backends: 1, percent: 100, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testOutlierDetectionConfig()
+			config.MaxEjectionPercent = tt.percent
+			od, targets := newTestOutlierDetector(t, tt.backends, config)
+
+			ejected := 0
+			for _, target := range targets {
+				failUntilEjected(od, target)
+				if od.Ejected(target) {
+					ejected++
+				}
+			}
+
+			if ejected != tt.expected {
+				t.Fatalf("ejected %d backends, expected %d", ejected, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOutlierDetectorLatencyOutlier(t *testing.T) {
+	const fast = 10 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		peers    int // measured backends besides the slow one
+		latency  time.Duration
+		samples  int
+		expected bool
+	}{
+		{name: "beyond the factor", peers: 3, latency: 5 * fast, samples: latencyOutlierSamples, expected: true},
+		{name: "within the factor", peers: 3, latency: 2 * fast, samples: latencyOutlierSamples, expected: false},
+		{name: "too few samples", peers: 3, latency: 5 * fast, samples: latencyOutlierSamples - 1, expected: false},
+		{name: "smallest median", peers: latencyOutlierPeers - 1, latency: 5 * fast, samples: latencyOutlierSamples, expected: true},
+		{name: "too few peers", peers: latencyOutlierPeers - 2, latency: 5 * fast, samples: latencyOutlierSamples, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testOutlierDetectionConfig()
+			config.SlowFactor = 3
+			od, targets := newTestOutlierDetector(t, 4, config)
+			slow := targets[len(targets)-1]
+
+			for _, peer := range targets[:tt.peers] {
+				for range latencyOutlierSamples {
+					od.ObserveResponse(peer, http.StatusOK, fast)
+				}
+			}
+
+			for range tt.samples {
+				od.ObserveResponse(slow, http.StatusOK, tt.latency)
+			}
+
+			if ejected := od.Ejected(slow); ejected != tt.expected {
+				t.Fatalf("ejected %v, expected %v", ejected, tt.expected)
+			}
+			for _, peer := range targets[:tt.peers] {
+				if od.Ejected(peer) {
+					t.Fatal("a fast backend was ejected")
+				}
+			}
+		})
+	}
+}
+
+func TestOutlierDetectorIgnoresRemovedBackend(t *testing.T) {
+	od, targets := newTestOutlierDetector(t, 2, testOutlierDetectionConfig())
+
+	if err := od.pool.Remove(targets[0]); err != nil {
+		t.Fatal(err)
+	}
+	od.forget(targets[0])
+
+	failUntilEjected(od, targets[0])
+	if _, ok := od.states[targets[0].String()]; ok {
+		t.Fatal("the state of a removed backend was recreated")
+	}
+}
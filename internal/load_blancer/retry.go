@@ -0,0 +1,397 @@
+package loadbalancer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/algorithms"
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/rs/zerolog/log"
+)
+
+// errPerTryTimeout cancels an attempt whose backend did not answer in time
+var errPerTryTimeout = errors.New("per try timeout exceeded")
+
+// RetryConfig configures retries of idempotent requests on other backends.
+type RetryConfig struct {
+	// MaxAttempts counts the first attempt too
+	MaxAttempts int
+	// RetryOn lists the statuses worth another backend, unreachable backends
+	// are always retried
+	RetryOn []int
+	// PerTryTimeout bounds the wait for the response headers of an attempt,
+	// zero waits as long as the server allows
+	PerTryTimeout time.Duration
+	// BaseBackoff doubles after every attempt up to MaxBackoff, the actual
+	// wait is drawn at random below it
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// BudgetRatio is the share of requests that may be retried, on top of a
+	// burst of BudgetBurst retries
+	BudgetRatio float64
+	BudgetBurst int
+	// MaxBodyBytes is the largest request body buffered for replay, larger
+	// requests are sent once
+	MaxBodyBytes int64
+}
+
+// DefaultRetryConfig retries gateway errors twice while retrying at most one
+// request out of five.
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:   3,
+		RetryOn:       []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		PerTryTimeout: 3 * time.Second,
+		BaseBackoff:   25 * time.Millisecond,
+		MaxBackoff:    250 * time.Millisecond,
+		BudgetRatio:   0.2,
+		BudgetBurst:   10,
+		MaxBodyBytes:  64 << 10,
+	}
+}
+
+func (c RetryConfig) validate() error {
+	switch {
+	case c.MaxAttempts < 1:
+		return fmt.Errorf("%w: max attempts must be at least 1", errs.ErrInvalidRetry)
+	case c.PerTryTimeout < 0 || c.BaseBackoff < 0 || c.MaxBackoff < c.BaseBackoff:
+		return fmt.Errorf("%w: timeouts must not be negative with max backoff at least base", errs.ErrInvalidRetry)
+	case c.BudgetRatio < 0 || c.BudgetBurst < 0:
+		return fmt.Errorf("%w: retry budget must not be negative", errs.ErrInvalidRetry)
+	case c.MaxBodyBytes < 0:
+		return fmt.Errorf("%w: max body bytes must not be negative", errs.ErrInvalidRetry)
+	}
+
+	for _, status := range c.RetryOn {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("%w: retry on %d is not an HTTP status", errs.ErrInvalidRetry, status)
+		}
+	}
+
+	return nil
+}
+
+// retryBudget caps retries to a share of the traffic, so a failing pool is
+// not hammered with retries on top of the regular load.
+type retryBudget struct {
+	ratio   float64
+	burst   float64
+	balance float64
+	mutex   sync.Mutex
+}
+
+func newRetryBudget(ratio float64, burst int) *retryBudget {
+	return &retryBudget{ratio: ratio, burst: float64(burst), balance: float64(burst)}
+}
+
+// deposit earns the retries allowed by one more request.
+func (b *retryBudget) deposit() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.balance = min(b.balance+b.ratio, max(b.burst, 1))
+}
+
+// withdraw spends one retry, it reports false when the budget is exhausted.
+func (b *retryBudget) withdraw() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.balance < 1 {
+		return false
+	}
+	b.balance--
+
+	return true
+}
+
+type retrier struct {
+	config RetryConfig
+	budget *retryBudget
+}
+
+func newRetrier(config RetryConfig) (*retrier, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return &retrier{
+		config: config,
+		budget: newRetryBudget(config.BudgetRatio, config.BudgetBurst),
+	}, nil
+}
+
+// eligible reports whether r may be sent more than once.
+func (rt *retrier) eligible(r *http.Request) bool {
+	return idempotent(r.Method) && rt.config.MaxAttempts > 1
+}
+
+// idempotent reports whether sending a request with method several times has
+// the same effect as sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// bufferBody reads the body of r so it can be replayed. A body larger than
+// maxBytes is left streaming and false is returned.
+func bufferBody(r *http.Request, maxBytes int64) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+
+	if r.ContentLength > maxBytes {
+		return nil, false, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if int64(len(body)) > maxBytes {
+		// Too large after all, send what was read followed by the rest
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false, nil
+	}
+
+	return body, true, nil
+}
+
+// backoff waits before the given retry, it returns early with false when the
+// client went away.
+func (rt *retrier) backoff(ctx context.Context, retry int) bool {
+	if rt.config.BaseBackoff == 0 {
+		return ctx.Err() == nil
+	}
+
+	ceiling := min(rt.config.BaseBackoff<<min(retry, 30), rt.config.MaxBackoff)
+	if ceiling <= 0 {
+		ceiling = rt.config.MaxBackoff
+	}
+	wait := rand.N(ceiling + 1) //nolint:gosec
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// serveWithRetries forwards r and, while attempts and budget remain, sends it
+// again to a backend not tried yet when the backend was unreachable or
+// answered a status of RetryOn.
+func (lb *loadBalanceHandler) serveWithRetries(w http.ResponseWriter, r *http.Request, impl AlgorithmImplementer) {
+	rt := lb.retry
+	rt.budget.deposit()
+
+	body, replayable, err := bufferBody(r, rt.config.MaxBodyBytes)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	tried := make([]string, 0, rt.config.MaxAttempts)
+	untried := func(candidate *url.URL) bool {
+		return !slices.Contains(tried, candidate.String()) && lb.usable(candidate)
+	}
+
+	for attempt := 1; ; attempt++ {
+		filter := algorithms.Filter(lb.usable)
+		if attempt > 1 {
+			filter = untried
+		}
+
+		target, release := lb.nextBackend(r, impl, filter)
+		if target == nil {
+			log.Warn().Err(errs.ErrNoHealthyBackend).Str("path", r.URL.Path).Msg("rejecting request")
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		tried = append(tried, target.String())
+
+		// A client that went away gets no retry, nor spends the budget on it
+		canRetry := func() bool {
+			return replayable && attempt < rt.config.MaxAttempts && r.Context().Err() == nil &&
+				slices.ContainsFunc(lb.pool.Backends(), func(b *pool.Backend) bool { return untried(b.GetUrl()) }) &&
+				rt.budget.withdraw()
+		}
+
+		retried := lb.attempt(w, r, impl, body, target, canRetry)
+		release()
+
+		if !retried {
+			return
+		}
+
+		log.Info().Str("backend", target.String()).Int("attempt", attempt).
+			Str("path", r.URL.Path).Msg("retrying request on another backend")
+
+		if !rt.backoff(r.Context(), attempt-1) {
+			return
+		}
+	}
+}
+
+// attempt forwards r to target once. The response is written to w unless it
+// failed and canRetry agreed to another attempt, which is then reported.
+func (lb *loadBalanceHandler) attempt(
+	w http.ResponseWriter, r *http.Request, impl AlgorithmImplementer,
+	body []byte, target *url.URL, canRetry func() bool,
+) bool {
+	ctx, cancel := context.WithCancelCause(r.Context())
+	defer cancel(nil)
+
+	outcome := &attemptOutcome{}
+	req := attemptRequest(lb.observed(r.WithContext(ctx)), body, outcome)
+
+	aw := &attemptWriter{
+		ResponseWriter: w,
+		discard: func(status int) bool {
+			failed := outcome.unreachable() || slices.Contains(lb.retry.config.RetryOn, status)
+			return failed && canRetry()
+		},
+	}
+
+	// Only the wait for the response headers is bounded, a long body is not
+	if lb.retry.config.PerTryTimeout > 0 {
+		timer := time.AfterFunc(lb.retry.config.PerTryTimeout, func() { cancel(errPerTryTimeout) })
+		defer timer.Stop()
+		aw.onHeader = func() { timer.Stop() }
+	}
+
+	impl.Forward(aw, req, target)
+
+	return aw.discarded
+}
+
+// attemptRequest prepares r for one attempt, replaying the buffered body.
+func attemptRequest(r *http.Request, body []byte, outcome *attemptOutcome) *http.Request {
+	req := algorithms.WithProxyObserver(r, outcome)
+	if body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	return req
+}
+
+// attemptOutcome learns whether the backend of an attempt answered at all.
+type attemptOutcome struct {
+	err   error
+	mutex sync.Mutex
+}
+
+func (o *attemptOutcome) ObserveResponse(*url.URL, int, time.Duration) {}
+
+func (o *attemptOutcome) ObserveError(_ *url.URL, err error, _ time.Duration) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.err = err
+}
+
+func (o *attemptOutcome) unreachable() bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return o.err != nil
+}
+
+// attemptWriter holds the response of an attempt back until its status is
+// known, then either passes it through or discards it, e.g. for a retry.
+type attemptWriter struct {
+	http.ResponseWriter
+	discard  func(status int) bool
+	onHeader func()
+
+	wroteHeader bool
+	discarded   bool
+	header      http.Header
+}
+
+// Header collects the response headers apart from the client ones, they are
+// only copied over once the attempt is kept.
+func (aw *attemptWriter) Header() http.Header {
+	if aw.wroteHeader && !aw.discarded {
+		return aw.ResponseWriter.Header()
+	}
+
+	if aw.header == nil {
+		aw.header = make(http.Header)
+	}
+	return aw.header
+}
+
+func (aw *attemptWriter) WriteHeader(status int) {
+	// Informational responses are not final, drop them until the attempt is
+	// known to be kept
+	if status >= 100 && status < 200 {
+		return
+	}
+
+	if aw.wroteHeader {
+		return
+	}
+	aw.wroteHeader = true
+
+	if aw.onHeader != nil {
+		aw.onHeader()
+	}
+
+	if aw.discard(status) {
+		aw.discarded = true
+		return
+	}
+
+	for key, values := range aw.header {
+		aw.ResponseWriter.Header()[key] = values
+	}
+	aw.ResponseWriter.WriteHeader(status)
+}
+
+func (aw *attemptWriter) Write(p []byte) (int, error) {
+	if !aw.wroteHeader {
+		aw.WriteHeader(http.StatusOK)
+	}
+
+	if aw.discarded {
+		return len(p), nil
+	}
+
+	return aw.ResponseWriter.Write(p)
+}
+
+func (aw *attemptWriter) Flush() {
+	if aw.discarded || !aw.wroteHeader {
+		return
+	}
+
+	if flusher, ok := aw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
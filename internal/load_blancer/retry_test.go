@@ -0,0 +1,184 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/algorithms"
+)
+
+func testRetryConfig() RetryConfig {
+	config := DefaultRetryConfig()
+	config.BaseBackoff = 0
+	config.MaxBackoff = 0
+
+	return config
+}
+
+// newRetryHandler balances over one backend per handler with retries on.
+func newRetryHandler(
+	t *testing.T, config RetryConfig, handlers ...http.HandlerFunc,
+) (*loadBalanceHandler, []*testBackend) {
+	t.Helper()
+
+	backends, servers := newTestBackends(t, handlers...)
+	hdl := newTestHandler(t, backends)
+
+	rt, err := newRetrier(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdl.retry = rt
+
+	return hdl, servers
+}
+
+func TestRetryReachesAnotherBackend(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			hdl, servers := newRetryHandler(t, testRetryConfig(), answer(status, "failed"), answer(http.StatusOK, "ok"))
+
+			// Round robin starts on the failing backend for some of them
+			for range 4 {
+				w := httptest.NewRecorder()
+				hdl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+				if w.Code != http.StatusOK || w.Body.String() != "ok" {
+					t.Fatalf("answered %d %q, expected the healthy backend", w.Code, w.Body.String())
+				}
+			}
+
+			if servers[0].hits.Load() == 0 {
+				t.Fatal("the failing backend was never tried")
+			}
+			if hits := servers[1].hits.Load(); hits != 4 {
+				t.Fatalf("healthy backend got %d requests, expected 4", hits)
+			}
+		})
+	}
+}
+
+func TestRetryReplaysOnlyBufferedBodies(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		hits int64
+	}{
+		{name: "small body is replayed", body: "abc", hits: 2},
+		{name: "large body is sent once", body: strings.Repeat("x", 64), hits: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received atomic.Value
+			readAndFail := func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				received.Store(string(body))
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+
+			config := testRetryConfig()
+			config.MaxBodyBytes = 16
+			hdl, servers := newRetryHandler(t, config, readAndFail, readAndFail)
+
+			w := httptest.NewRecorder()
+			hdl.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(tt.body)))
+
+			if w.Code != http.StatusServiceUnavailable {
+				t.Fatalf("answered %d, expected the last failure to pass through", w.Code)
+			}
+			if hits := servers[0].hits.Load() + servers[1].hits.Load(); hits != tt.hits {
+				t.Fatalf("sent %d times, expected %d", hits, tt.hits)
+			}
+			if body := received.Load(); body != tt.body {
+				t.Fatalf("backend received %q, expected the whole body %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestRetryBudgetExhaustion(t *testing.T) {
+	config := testRetryConfig()
+	config.BudgetRatio = 0
+	config.BudgetBurst = 1
+
+	failing := answer(http.StatusServiceUnavailable, "failed")
+	hdl, servers := newRetryHandler(t, config, failing, failing, failing)
+
+	totalHits := func() int64 {
+		var hits int64
+		for _, server := range servers {
+			hits += server.hits.Load()
+		}
+		return hits
+	}
+
+	// The burst pays for one retry, then requests are sent once
+	for idx, expected := range []int64{2, 3, 4} {
+		w := httptest.NewRecorder()
+		hdl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("request %d answered %d, expected 503", idx, w.Code)
+		}
+		if hits := totalHits(); hits != expected {
+			t.Fatalf("after request %d backends got %d requests, expected %d", idx, hits, expected)
+		}
+	}
+}
+
+func TestRetryPerTryTimeout(t *testing.T) {
+	config := testRetryConfig()
+	config.PerTryTimeout = 50 * time.Millisecond
+
+	hdl, servers := newRetryHandler(t, config,
+		answerAfter(5*time.Second, http.StatusOK, "slow"), answer(http.StatusOK, "fast"))
+	slow := servers[0]
+
+	observer := newRecordingObserver()
+	for range 2 {
+		r := algorithms.WithProxyObserver(httptest.NewRequest(http.MethodGet, "/", nil), observer)
+		w := httptest.NewRecorder()
+		hdl.ServeHTTP(w, r)
+
+		if w.Code != http.StatusOK || w.Body.String() != "fast" {
+			t.Fatalf("answered %d %q, expected the fast backend", w.Code, w.Body.String())
+		}
+	}
+
+	errs := observer.errorsOf(slow.url)
+	if len(errs) == 0 {
+		t.Fatal("the timed out attempt was not reported")
+	}
+	for _, err := range errs {
+		if !errors.Is(err, errPerTryTimeout) {
+			t.Fatalf("reported %v, expected %v", err, errPerTryTimeout)
+		}
+	}
+}
+
+func TestRetryCanceledClientKeepsBudget(t *testing.T) {
+	config := testRetryConfig()
+	config.BudgetRatio = 0
+	config.BudgetBurst = 1
+
+	slow := answerAfter(5*time.Second, http.StatusOK, "slow")
+	hdl, _ := newRetryHandler(t, config, slow, slow)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	w := httptest.NewRecorder()
+	hdl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if !hdl.retry.budget.withdraw() {
+		t.Fatal("an aborted client spent the retry budget")
+	}
+}
@@ -0,0 +1,167 @@
+package loadbalancer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DucTran999/load-balancing-algo/internal/algorithms"
+	"github.com/DucTran999/load-balancing-algo/internal/pool"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultStickyCookieName is the cookie pinning a client to its backend
+	DefaultStickyCookieName = "lb_sticky"
+	// DefaultStickyMaxAge is how long a client stays pinned without coming back
+	DefaultStickyMaxAge = time.Hour
+)
+
+// StickySessionConfig configures the session affinity layer. An empty Secret
+// gets a random one, pins then do not survive a restart of the balancer.
+type StickySessionConfig struct {
+	CookieName string
+	Secret     []byte
+	MaxAge     time.Duration
+}
+
+// stickySession pins a client to the backend chosen for its first request.
+// The cookie holds an opaque backend id signed with HMAC-SHA256, so clients
+// can neither read the backend address nor pick a backend themselves.
+type stickySession struct {
+	next   AlgorithmImplementer
+	config StickySessionConfig
+	pool   *pool.Pool
+}
+
+func newStickySession(
+	next AlgorithmImplementer, backends *pool.Pool, config StickySessionConfig,
+) (*stickySession, error) {
+	if config.CookieName == "" {
+		config.CookieName = DefaultStickyCookieName
+	}
+
+	if config.MaxAge <= 0 {
+		config.MaxAge = DefaultStickyMaxAge
+	}
+
+	if len(config.Secret) == 0 {
+		config.Secret = make([]byte, sha256.Size)
+		if _, err := rand.Read(config.Secret); err != nil {
+			return nil, err
+		}
+	}
+
+	ss := &stickySession{
+		next:   next,
+		config: config,
+		pool:   backends,
+	}
+
+	return ss, nil
+}
+
+// wrap returns a session layer pinning clients the same way in front of next,
+// pins handed out so far stay valid.
+func (ss *stickySession) wrap(next AlgorithmImplementer) *stickySession {
+	return &stickySession{next: next, config: ss.config, pool: ss.pool}
+}
+
+func (ss *stickySession) ForwardRequest(w http.ResponseWriter, r *http.Request) {
+	target, release := ss.NextBackend(r, nil)
+	if target == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	ss.Forward(w, r, target)
+}
+
+// NextBackend returns the pinned backend while filter accepts it, e.g. it is
+// healthy, otherwise lets the wrapped algorithm choose.
+func (ss *stickySession) NextBackend(r *http.Request, filter algorithms.Filter) (*url.URL, func()) {
+	if pinned, ok := ss.pinned(r); ok && filter.Allows(pinned) {
+		// The wrapped algorithm is left the pinned backend only, so it still
+		// counts the request, e.g. towards least connection
+		only := func(candidate *url.URL) bool { return candidate.String() == pinned.String() }
+		if target, release := ss.next.NextBackend(r, only); target != nil {
+			return target, release
+		}
+	}
+
+	return ss.next.NextBackend(r, filter)
+}
+
+// Forward refreshes the pin on every response, which re-pins clients whose
+// backend went away and slides the expiry of the others.
+func (ss *stickySession) Forward(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     ss.config.CookieName,
+		Value:    ss.sign(backendID(target)),
+		Path:     "/",
+		MaxAge:   int(ss.config.MaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	ss.next.Forward(w, r, target)
+}
+
+func (ss *stickySession) pinned(r *http.Request) (*url.URL, bool) {
+	cookie, err := r.Cookie(ss.config.CookieName)
+	if err != nil {
+		return nil, false
+	}
+
+	id, ok := ss.verify(cookie.Value)
+	if !ok {
+		log.Debug().Str("cookie", cookie.Value).Msg("ignoring sticky cookie with bad signature")
+		return nil, false
+	}
+
+	// A pin to a backend removed from the pool matches nothing
+	for _, b := range ss.pool.Backends() {
+		if backendID(b.GetUrl()) == id {
+			return b.GetUrl(), true
+		}
+	}
+
+	return nil, false
+}
+
+// sign returns "<id>.<mac>" where mac authenticates id with the secret.
+func (ss *stickySession) sign(id string) string {
+	return id + "." + hex.EncodeToString(ss.mac(id))
+}
+
+func (ss *stickySession) verify(value string) (string, bool) {
+	id, sig, found := strings.Cut(value, ".")
+	if !found {
+		return "", false
+	}
+
+	mac, err := hex.DecodeString(sig)
+	if err != nil {
+		return "", false
+	}
+
+	return id, hmac.Equal(mac, ss.mac(id))
+}
+
+func (ss *stickySession) mac(id string) []byte {
+	h := hmac.New(sha256.New, ss.config.Secret)
+	h.Write([]byte(id)) //nolint:gosec
+	return h.Sum(nil)
+}
+
+// backendID hides the backend address behind a short digest of it.
+func backendID(target *url.URL) string {
+	sum := sha256.Sum256([]byte(target.String()))
+	return hex.EncodeToString(sum[:8])
+}
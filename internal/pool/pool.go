@@ -0,0 +1,225 @@
+package pool
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/DucTran999/load-balancing-algo/internal/errs"
+)
+
+// Mode tells whether a backend receives new requests.
+type Mode int32
+
+const (
+	// ModeActive backends take their share of the traffic
+	ModeActive Mode = iota
+	// ModeDraining backends finish their requests in flight without
+	// receiving new ones
+	ModeDraining
+	// ModeMaintenance backends are drained and no longer probed either, they
+	// come back with a clean state
+	ModeMaintenance
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeActive:
+		return "active"
+	case ModeDraining:
+		return "draining"
+	case ModeMaintenance:
+		return "maintenance"
+	default:
+		return ""
+	}
+}
+
+func (m Mode) MarshalText() ([]byte, error) {
+	if m.String() == "" {
+		return nil, fmt.Errorf("%w: %d", errs.ErrInvalidMode, m)
+	}
+
+	return []byte(m.String()), nil
+}
+
+func (m *Mode) UnmarshalText(text []byte) error {
+	for _, mode := range []Mode{ModeActive, ModeDraining, ModeMaintenance} {
+		if mode.String() == string(text) {
+			*m = mode
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q", errs.ErrInvalidMode, text)
+}
+
+// Backend is a member of the pool. Its URL never changes while its weight and
+// mode are updated in place, so every algorithm sees them right away.
+type Backend struct {
+	url    *url.URL
+	weight atomic.Int64
+	mode   atomic.Int32
+}
+
+func (b *Backend) GetUrl() *url.URL {
+	return b.url
+}
+
+func (b *Backend) GetWeight() int {
+	return int(b.weight.Load())
+}
+
+func (b *Backend) Mode() Mode {
+	return Mode(b.mode.Load())
+}
+
+// snapshot is an immutable view of the members, replaced as a whole on every
+// change.
+type snapshot struct {
+	version  uint64
+	backends []*Backend
+	index    map[string]*Backend
+}
+
+// Pool is the set of backends shared by the load balancer and its algorithm.
+// Readers take a snapshot without locking, writers publish a new one with a
+// higher version, which tells algorithms to rebuild whatever they derived
+// from the previous members, e.g. a hash ring.
+type Pool struct {
+	current atomic.Pointer[snapshot]
+	mutex   sync.Mutex
+}
+
+func New() *Pool {
+	p := &Pool{}
+	p.current.Store(&snapshot{index: map[string]*Backend{}})
+
+	return p
+}
+
+// Backends returns the current members, the slice must not be modified.
+func (p *Pool) Backends() []*Backend {
+	return p.current.Load().backends
+}
+
+// Snapshot returns the current members along with their version.
+func (p *Pool) Snapshot() (uint64, []*Backend) {
+	s := p.current.Load()
+	return s.version, s.backends
+}
+
+// Version increases every time the members or their weights change.
+func (p *Pool) Version() uint64 {
+	return p.current.Load().version
+}
+
+func (p *Pool) Len() int {
+	return len(p.current.Load().backends)
+}
+
+// Get returns the member with the given URL.
+func (p *Pool) Get(target *url.URL) (*Backend, bool) {
+	b, ok := p.current.Load().index[target.String()]
+	return b, ok
+}
+
+// Add puts a new backend with weight into the pool.
+func (p *Pool) Add(target *url.URL, weight int) error {
+	if target == nil || target.Host == "" {
+		return errs.ErrInvalidBackendUrl
+	}
+
+	if weight < 1 {
+		return fmt.Errorf("%w: %d", errs.ErrInvalidWeight, weight)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	current := p.current.Load()
+	if _, ok := current.index[target.String()]; ok {
+		return fmt.Errorf("%w: %s", errs.ErrBackendExists, target.String())
+	}
+
+	b := &Backend{url: target}
+	b.weight.Store(int64(weight))
+
+	p.publish(current, append(slices.Clip(current.backends), b))
+	return nil
+}
+
+// Remove takes target out of the pool. Requests already forwarded to it are
+// not affected and complete normally. The last backend cannot be removed.
+func (p *Pool) Remove(target *url.URL) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	current := p.current.Load()
+	if _, ok := current.index[target.String()]; !ok {
+		return fmt.Errorf("%w: %s", errs.ErrBackendNotFound, target.String())
+	}
+
+	if len(current.backends) == 1 {
+		return errs.ErrLastBackend
+	}
+
+	p.publish(current, slices.DeleteFunc(slices.Clone(current.backends), func(b *Backend) bool {
+		return b.url.String() == target.String()
+	}))
+	return nil
+}
+
+// SetWeight changes the weight of target. It bumps the version since weights
+// shape the tables some algorithms precompute.
+func (p *Pool) SetWeight(target *url.URL, weight int) error {
+	if weight < 1 {
+		return fmt.Errorf("%w: %d", errs.ErrInvalidWeight, weight)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	current := p.current.Load()
+	b, ok := current.index[target.String()]
+	if !ok {
+		return fmt.Errorf("%w: %s", errs.ErrBackendNotFound, target.String())
+	}
+
+	if b.weight.Swap(int64(weight)) != int64(weight) {
+		p.publish(current, current.backends)
+	}
+	return nil
+}
+
+// SetMode switches the mode of target. Modes are applied by the load balancer
+// when it filters the backends, so the version stays the same.
+func (p *Pool) SetMode(target *url.URL, mode Mode) error {
+	if mode.String() == "" {
+		return fmt.Errorf("%w: %d", errs.ErrInvalidMode, mode)
+	}
+
+	b, ok := p.Get(target)
+	if !ok {
+		return fmt.Errorf("%w: %s", errs.ErrBackendNotFound, target.String())
+	}
+
+	b.mode.Store(int32(mode))
+	return nil
+}
+
+// publish replaces the current snapshot by backends. Callers hold the mutex.
+func (p *Pool) publish(current *snapshot, backends []*Backend) {
+	index := make(map[string]*Backend, len(backends))
+	for _, b := range backends {
+		index[b.url.String()] = b
+	}
+
+	p.current.Store(&snapshot{
+		version:  current.version + 1,
+		backends: backends,
+		index:    index,
+	})
+}
@@ -27,6 +27,35 @@ func NewRequestSender(numRequests int) *requestSender {
 	}
 }
 
+// NewSequentialRequestSender sends one request after the other, so the
+// traffic spreads over time.
+func NewSequentialRequestSender(numRequests int) *requestSender {
+	cfg := requester.Config{
+		NumOfRequest: numRequests,
+		Mode:         requester.SequentialMode,
+		Jitter:       time.Second,
+	}
+
+	return &requestSender{
+		sender: requester.NewRequester(cfg),
+	}
+}
+
+// NewSessionRequestSender sends requests carrying the cookies set by earlier
+// responses, as a single browser session would.
+func NewSessionRequestSender(numRequests int) *requestSender {
+	cfg := requester.Config{
+		NumOfRequest: numRequests,
+		Mode:         requester.SequentialMode,
+		Jitter:       time.Second,
+		KeepCookies:  true,
+	}
+
+	return &requestSender{
+		sender: requester.NewRequester(cfg),
+	}
+}
+
 func (r *requestSender) SendNow() {
 	r.sender.Start(r.sendRequest)
 }
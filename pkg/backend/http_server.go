@@ -2,6 +2,7 @@ package backend
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/rand"
@@ -12,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/DucTran999/load-balancing-algo/pkg/loadreport"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 )
@@ -19,6 +21,10 @@ import (
 const (
 	DefaultMaxConnection = 10
 	DefaultMinConnection = 1
+	DefaultMaxQueueDepth = 50
+
+	// HealthPath answers 200 while the server is up, for active health checks
+	HealthPath = "/healthz"
 )
 
 var r *rand.Rand
@@ -36,6 +42,11 @@ type SimpleHTTPServer struct {
 	weight     int
 	connection int
 	cpuLoad    float64
+	memoryLoad float64
+	queueDepth int
+	rps        rpsCounter
+	failRate   float64
+	extraDelay time.Duration
 	mutex      sync.Mutex
 	latency    time.Duration
 	router     *mux.Router
@@ -118,29 +129,92 @@ func (s *SimpleHTTPServer) Stop(ctx context.Context) error {
 	return nil
 }
 
+// SimulateFailures makes the server answer the given fraction of requests with
+// a 500, to exercise passive health checks.
+func (s *SimpleHTTPServer) SimulateFailures(rate float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.failRate = rate
+}
+
+// SimulateDelay makes the server wait the given extra time before answering,
+// to exercise latency based features.
+func (s *SimpleHTTPServer) SimulateDelay(delay time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.extraDelay = delay
+}
+
 // Handler method
 func (s *SimpleHTTPServer) reqHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	reqID := vars["req_id"]
+
+	if s.simulateFailure() {
+		http.Error(w, fmt.Sprintf("Server %d failed request %s", s.id, reqID), http.StatusInternalServerError)
+		return
+	}
 	handleTime := time.Second * time.Duration(1/s.weight)
-	time.Sleep(handleTime)
+	time.Sleep(handleTime + s.simulatedDelay())
 
+	s.mutex.Lock()
 	// Simulate change the connection to this backend server
 	s.connection = s.randomConnectionNumber(DefaultMinConnection, DefaultMaxConnection)
-
-	s.mutex.Lock()
 	s.latency = time.Duration(s.simulateResponseTime()) * time.Millisecond
 	s.cpuLoad = s.simulateCPULoad()
+	s.memoryLoad = s.simulateMemoryLoad()
+	s.queueDepth = s.simulateQueueDepth()
+	s.rps.add(time.Now())
+	doc := s.loadDocument()
 	s.mutex.Unlock()
 
+	// Let the load balancer know how loaded this server is
+	w.Header().Set(loadreport.Header, doc.Report().Encode())
+
 	if _, err := fmt.Fprintf(w, "Server %d, handle request %s!", s.id, reqID); err != nil {
 		log.Error().Err(err).Msg("failed to write response")
 	}
 }
 
+// loadHandler serves the current load for load balancers polling out of band.
+func (s *SimpleHTTPServer) loadHandler(w http.ResponseWriter, _ *http.Request) {
+	s.mutex.Lock()
+	doc := s.loadDocument()
+	s.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		log.Error().Err(err).Msg("failed to write load report")
+	}
+}
+
+// healthHandler tells health checkers the server is able to serve requests.
+func (s *SimpleHTTPServer) healthHandler(w http.ResponseWriter, _ *http.Request) {
+	if _, err := fmt.Fprint(w, "ok"); err != nil {
+		log.Error().Err(err).Msg("failed to write health status")
+	}
+}
+
+// loadDocument snapshots the simulated metrics, the caller must hold the mutex.
+func (s *SimpleHTTPServer) loadDocument() loadreport.Document {
+	return loadreport.Document{
+		Weight:         s.weight,
+		Connections:    s.connection,
+		CPUUtilization: s.cpuLoad / 100,
+		MemUtilization: s.memoryLoad / 100,
+		QueueDepth:     s.queueDepth,
+		LatencyMs:      s.latency.Milliseconds(),
+		RPS:            s.rps.rate(time.Now()),
+	}
+}
+
 // Method to initialize routes
 func (s *SimpleHTTPServer) routes() {
 	s.router.HandleFunc("/req/{req_id}", s.reqHandler)
+	s.router.HandleFunc(loadreport.Path, s.loadHandler).Methods(http.MethodGet)
+	s.router.HandleFunc(HealthPath, s.healthHandler).Methods(http.MethodGet, http.MethodHead)
 }
 
 func (s *SimpleHTTPServer) randomConnectionNumber(min, max int) int {
@@ -159,3 +233,30 @@ func (s *SimpleHTTPServer) simulateCPULoad() float64 {
 	f := r.Float64()*(max-min) + min
 	return math.Round(f*100) / 100
 }
+
+func (s *SimpleHTTPServer) simulateMemoryLoad() float64 {
+	min := 20.0
+	max := 95.0
+
+	// Generate a float in [20.0, 95.0)
+	f := r.Float64()*(max-min) + min
+	return math.Round(f*100) / 100
+}
+
+func (s *SimpleHTTPServer) simulatedDelay() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.extraDelay
+}
+
+func (s *SimpleHTTPServer) simulateFailure() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.failRate > 0 && r.Float64() < s.failRate
+}
+
+func (s *SimpleHTTPServer) simulateQueueDepth() int {
+	return r.Intn(DefaultMaxQueueDepth + 1)
+}
@@ -0,0 +1,35 @@
+package backend
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DucTran999/load-balancing-algo/pkg/loadreport"
+)
+
+func TestLoadHandlerReportsWeight(t *testing.T) {
+	server := NewSimpleHTTPServer("localhost", 8081, 1, 3)
+	server.routes()
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, loadreport.Path, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, expected 200", w.Code)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"weight", "connections", "cpu_utilization", "latency_ms"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("load document %s misses %q", w.Body.String(), name)
+		}
+	}
+	if fields["weight"] != float64(3) {
+		t.Errorf("weight %v, expected 3", fields["weight"])
+	}
+}
@@ -0,0 +1,40 @@
+package backend
+
+import "time"
+
+const rpsWindow = time.Second
+
+// rpsCounter estimates requests per second over fixed one second windows, the
+// rate of the previous window is reported until the current one completes.
+type rpsCounter struct {
+	windowStart time.Time
+	count       int
+	lastRate    float64
+}
+
+func (c *rpsCounter) add(now time.Time) {
+	c.roll(now)
+	c.count++
+}
+
+func (c *rpsCounter) rate(now time.Time) float64 {
+	c.roll(now)
+	return c.lastRate
+}
+
+func (c *rpsCounter) roll(now time.Time) {
+	elapsed := now.Sub(c.windowStart)
+	if elapsed < rpsWindow {
+		return
+	}
+
+	// A window that ended long ago means no traffic since then
+	if elapsed >= 2*rpsWindow {
+		c.lastRate = 0
+	} else {
+		c.lastRate = float64(c.count) / elapsed.Seconds()
+	}
+
+	c.windowStart = now
+	c.count = 0
+}
@@ -0,0 +1,31 @@
+package loadreport
+
+// Path is where backends serve their load as JSON for out of band polling.
+const Path = "/load"
+
+// Document is the JSON body served on Path. Utilizations are fractions in
+// [0, 1] like in Report.
+type Document struct {
+	Weight         int     `json:"weight"`
+	Connections    int     `json:"connections"`
+	CPUUtilization float64 `json:"cpu_utilization"`
+	MemUtilization float64 `json:"mem_utilization"`
+	QueueDepth     int     `json:"queue_depth"`
+	LatencyMs      int64   `json:"latency_ms"`
+	RPS            float64 `json:"rps"`
+}
+
+// Report converts the polled document to the report received in headers, so
+// both sources feed the load balancer the same way.
+func (d Document) Report() Report {
+	return Report{
+		CPUUtilization: d.CPUUtilization,
+		MemUtilization: d.MemUtilization,
+		RPS:            d.RPS,
+		NamedMetrics: map[string]float64{
+			QueueDepthMetric:  float64(d.QueueDepth),
+			ConnectionsMetric: float64(d.Connections),
+			LatencyMetric:     float64(d.LatencyMs),
+		},
+	}
+}
@@ -0,0 +1,123 @@
+package loadreport
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Header is the response header a backend attaches its load report to. The
+// name and the TEXT encoding follow the ORCA endpoint load metrics format:
+//
+//	Endpoint-Load-Metrics: TEXT cpu_utilization=0.3, mem_utilization=0.8, rps_fractional=10, named_metrics.queue_depth=12
+const Header = "Endpoint-Load-Metrics"
+
+const (
+	textPrefix = "TEXT "
+
+	cpuUtilizationKey = "cpu_utilization"
+	memUtilizationKey = "mem_utilization"
+	rpsKey            = "rps_fractional"
+	namedMetricPrefix = "named_metrics."
+)
+
+// Well known named metrics reported by the simulated backends.
+const (
+	QueueDepthMetric  = "queue_depth"
+	ConnectionsMetric = "connections"
+	LatencyMetric     = "latency_ms"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported load report format")
+	ErrMalformedMetric   = errors.New("malformed load report metric")
+)
+
+// Report is the load a backend reports about itself. Utilizations are
+// fractions in [0, 1].
+type Report struct {
+	CPUUtilization float64
+	MemUtilization float64
+	RPS            float64
+	NamedMetrics   map[string]float64
+}
+
+// Named returns the named metric called name, false when it was not reported.
+func (r Report) Named(name string) (float64, bool) {
+	value, ok := r.NamedMetrics[name]
+	return value, ok
+}
+
+// Encode renders the report as an ORCA TEXT header value.
+func (r Report) Encode() string {
+	metrics := []string{
+		fmt.Sprintf("%s=%s", cpuUtilizationKey, formatFloat(r.CPUUtilization)),
+		fmt.Sprintf("%s=%s", memUtilizationKey, formatFloat(r.MemUtilization)),
+		fmt.Sprintf("%s=%s", rpsKey, formatFloat(r.RPS)),
+	}
+
+	// Sort the named metrics so the header is stable
+	names := make([]string, 0, len(r.NamedMetrics))
+	for name := range r.NamedMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		metrics = append(metrics, fmt.Sprintf(
+			"%s%s=%s", namedMetricPrefix, name, formatFloat(r.NamedMetrics[name]),
+		))
+	}
+
+	return textPrefix + strings.Join(metrics, ", ")
+}
+
+// Parse decodes an ORCA TEXT header value. Unknown keys are ignored so
+// backends can report more than the load balancer understands, values that are
+// negative or not finite are malformed.
+func Parse(value string) (Report, error) {
+	body, ok := strings.CutPrefix(strings.TrimSpace(value), textPrefix)
+	if !ok {
+		return Report{}, ErrUnsupportedFormat
+	}
+
+	report := Report{NamedMetrics: make(map[string]float64)}
+	for _, metric := range strings.Split(body, ",") {
+		metric = strings.TrimSpace(metric)
+		if metric == "" {
+			continue
+		}
+
+		key, rawValue, found := strings.Cut(metric, "=")
+		if !found {
+			return Report{}, fmt.Errorf("%w: %q", ErrMalformedMetric, metric)
+		}
+
+		// Loads are never negative, and NaN or infinities would poison every
+		// score computed from them
+		number, err := strconv.ParseFloat(strings.TrimSpace(rawValue), 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) || number < 0 {
+			return Report{}, fmt.Errorf("%w: %q", ErrMalformedMetric, metric)
+		}
+
+		switch key = strings.TrimSpace(key); {
+		case key == cpuUtilizationKey:
+			report.CPUUtilization = number
+		case key == memUtilizationKey:
+			report.MemUtilization = number
+		case key == rpsKey:
+			report.RPS = number
+		case strings.HasPrefix(key, namedMetricPrefix):
+			report.NamedMetrics[strings.TrimPrefix(key, namedMetricPrefix)] = number
+		}
+	}
+
+	return report, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,46 @@
+package loadreport
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRejectsInvalidValues(t *testing.T) {
+	tests := []string{
+		"TEXT cpu_utilization=-0.1",
+		"TEXT mem_utilization=NaN",
+		"TEXT rps_fractional=+Inf",
+		"TEXT named_metrics.queue_depth=-Inf",
+		"TEXT named_metrics.latency_ms=-3",
+	}
+
+	for _, value := range tests {
+		if _, err := Parse(value); !errors.Is(err, ErrMalformedMetric) {
+			t.Errorf("Parse(%q) returned %v, expected %v", value, err, ErrMalformedMetric)
+		}
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	report := Report{
+		CPUUtilization: 0.3,
+		MemUtilization: 0.8,
+		RPS:            10,
+		NamedMetrics:   map[string]float64{QueueDepthMetric: 12, LatencyMetric: 0},
+	}
+
+	parsed, err := Parse(report.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.CPUUtilization != report.CPUUtilization || parsed.MemUtilization != report.MemUtilization ||
+		parsed.RPS != report.RPS || len(parsed.NamedMetrics) != len(report.NamedMetrics) {
+		t.Fatalf("parsed %+v, expected %+v", parsed, report)
+	}
+	for name, value := range report.NamedMetrics {
+		if parsed.NamedMetrics[name] != value {
+			t.Fatalf("parsed %+v, expected %+v", parsed, report)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package requester
 import (
 	"log"
 	"net/http"
+	"net/http/cookiejar"
 	"sync"
 	"time"
 )
@@ -45,7 +46,7 @@ func (r *requester) applyConfig() {
 func (r *requester) sendParallel(fn DoRequestCallback) {
 	wg := sync.WaitGroup{}
 
-	c := http.Client{Timeout: 10 * time.Second}
+	c := r.newClient()
 
 	for i := range r.config.NumOfRequest {
 		wg.Add(1)
@@ -62,7 +63,7 @@ func (r *requester) sendParallel(fn DoRequestCallback) {
 }
 
 func (r *requester) sendSequential(fn DoRequestCallback) {
-	c := http.Client{Timeout: 10 * time.Second}
+	c := r.newClient()
 
 	for i := range r.config.NumOfRequest {
 		fn(c, i)
@@ -71,3 +72,14 @@ func (r *requester) sendSequential(fn DoRequestCallback) {
 		time.Sleep(r.config.Jitter)
 	}
 }
+
+func (r *requester) newClient() http.Client {
+	c := http.Client{Timeout: 10 * time.Second}
+
+	if r.config.KeepCookies {
+		// A jar without public suffix list never fails to build
+		c.Jar, _ = cookiejar.New(nil)
+	}
+
+	return c
+}
@@ -6,4 +6,7 @@ type Config struct {
 	NumOfRequest int
 	Mode         RequestSenderMode
 	Jitter       time.Duration
+
+	// KeepCookies makes every request share one cookie jar, like a browser
+	KeepCookies bool
 }